@@ -37,6 +37,35 @@ func NewErrInvalidMaxIntervalValue(maxInterval, initialInterval time.Duration) e
 	return fmt.Errorf("ekit: 最大重试间隔的时间 [%d] 应大于等于初始重试的间隔时间 [%d] ", maxInterval, initialInterval)
 }
 
-func NewErrRetryExhausted(lastErr error) error {
-	return fmt.Errorf("ekit: 超过最大重试次数，业务返回的最后一个 error %w", lastErr)
+func NewErrInvalidRetryBudgetRatio(ratio float64) error {
+	return fmt.Errorf("ekit: 无效的重试预算比例 %v, 预期值应大于等于 0", ratio)
+}
+
+func NewErrInvalidRetryBudgetWindow(window time.Duration) error {
+	return fmt.Errorf("ekit: 无效的重试预算窗口 %d, 预期值应大于 0", window)
+}
+
+func NewErrInvalidBreakerSize(windowSize, minRequests, halfOpenProbes int) error {
+	return fmt.Errorf("ekit: 无效的熔断器配置，windowSize %d，minRequests %d 和 halfOpenProbes %d 都应大于 0",
+		windowSize, minRequests, halfOpenProbes)
+}
+
+func NewErrInvalidBreakerMinRequests(minRequests, windowSize int) error {
+	return fmt.Errorf("ekit: 无效的熔断器配置，minRequests %d 不能大于 windowSize %d", minRequests, windowSize)
+}
+
+func NewErrInvalidBreakerFailureRate(rate float64) error {
+	return fmt.Errorf("ekit: 无效的熔断器失败率阈值 %v, 预期值应在 (0, 1] 之间", rate)
+}
+
+func NewErrInvalidBreakerOpenTimeout(timeout time.Duration) error {
+	return fmt.Errorf("ekit: 无效的熔断器打开时间 %d, 预期值应大于 0", timeout)
+}
+
+func NewErrInvalidPercentile(percentile float64) error {
+	return fmt.Errorf("ekit: 无效的分位数 %v, 预期值应在 (0, 1] 之间", percentile)
+}
+
+func NewErrInvalidWindowSize(size int) error {
+	return fmt.Errorf("ekit: 无效的窗口大小 %d, 预期值应大于 0", size)
 }
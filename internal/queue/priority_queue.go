@@ -74,15 +74,17 @@ func (p *PriorityQueue[T]) Enqueue(t T) error {
 	}
 
 	p.data = append(p.data, t)
-	node := len(p.data) - 1
+	p.siftUp(len(p.data) - 1)
+	return nil
+}
+
+func (p *PriorityQueue[T]) siftUp(node int) {
 	parent := (node - 1) / 2
 	for parent >= 0 && p.compare(p.data[node], p.data[parent]) < 0 {
 		p.data[parent], p.data[node] = p.data[node], p.data[parent]
 		node = parent
 		parent = (parent - 1) >> 1
 	}
-
-	return nil
 }
 
 func (p *PriorityQueue[T]) Dequeue() (T, error) {
@@ -99,6 +101,46 @@ func (p *PriorityQueue[T]) Dequeue() (T, error) {
 	return pop, nil
 }
 
+// PeekLast 返回最后一个出队的元素，也就是最大的元素
+func (p *PriorityQueue[T]) PeekLast() (T, error) {
+	if p.isEmpty() {
+		var t T
+		return t, ErrEmptyQueue
+	}
+	return p.data[p.lastPos()], nil
+}
+
+// DequeueLast 移除并返回最后一个出队的元素，也就是最大的元素
+func (p *PriorityQueue[T]) DequeueLast() (T, error) {
+	if p.isEmpty() {
+		var t T
+		return t, ErrEmptyQueue
+	}
+	pos := p.lastPos()
+	last := p.data[pos]
+	n := len(p.data) - 1
+	p.data[pos] = p.data[n]
+	p.data = p.data[:n]
+	if pos < n {
+		// pos 是叶子节点，被移动过来的元素只可能需要上浮
+		p.siftUp(pos)
+	}
+	p.shrinkIfNecessary()
+	return last, nil
+}
+
+// lastPos 返回最大的元素的下标
+// 最大的元素一定是叶子节点，所以只需要遍历后一半元素
+func (p *PriorityQueue[T]) lastPos() int {
+	pos := len(p.data) / 2
+	for i := pos + 1; i < len(p.data); i++ {
+		if p.compare(p.data[i], p.data[pos]) > 0 {
+			pos = i
+		}
+	}
+	return pos
+}
+
 func (p *PriorityQueue[T]) shrinkIfNecessary() {
 	if p.IsBoundless() {
 		p.data = slice.Shrink[T](p.data)
@@ -306,6 +306,62 @@ func TestPriorityQueue_Dequeue(t *testing.T) {
 	}
 }
 
+func TestPriorityQueue_DequeueLast(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     []int
+		wantErr  error
+		wantLast []int
+		wantRest []int
+	}{
+		{
+			name:    "空队列",
+			data:    []int{},
+			wantErr: ErrEmptyQueue,
+		},
+		{
+			name:     "只有一个元素",
+			data:     []int{10},
+			wantLast: []int{10},
+			wantRest: []int{},
+		},
+		{
+			name:     "many",
+			data:     []int{9, 2, 7, 4, 8, 1, 3, 6, 5},
+			wantLast: []int{9, 8, 7},
+			wantRest: []int{1, 2, 3, 4, 5, 6},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q := priorityQueueOf(0, tc.data, compare())
+			require.NotNil(t, q)
+			last := make([]int, 0, len(tc.wantLast))
+			for i := 0; i < len(tc.wantLast) || i == 0; i++ {
+				peek, err := q.PeekLast()
+				assert.Equal(t, tc.wantErr, err)
+				val, err := q.DequeueLast()
+				assert.Equal(t, tc.wantErr, err)
+				if err != nil {
+					return
+				}
+				assert.Equal(t, peek, val)
+				last = append(last, val)
+			}
+			assert.Equal(t, tc.wantLast, last)
+			// 剩下的元素依旧满足堆的性质
+			rest := make([]int, 0, q.Len())
+			for q.Len() > 0 {
+				val, err := q.Dequeue()
+				require.NoError(t, err)
+				rest = append(rest, val)
+			}
+			assert.Equal(t, tc.wantRest, rest)
+		})
+	}
+}
+
 func TestPriorityQueue_DequeueComplexCheck(t *testing.T) {
 	testCases := []struct {
 		name     string
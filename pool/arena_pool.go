@@ -19,54 +19,207 @@ package pool
 import (
 	"arena"
 	"sync"
+	"time"
+
+	"github.com/ecodeclub/ekit/bean/option"
 )
 
 type ArenaPool[T any] struct {
 	chain []*Arena[T]
 	mutex sync.RWMutex
+
+	// 最多保留多少个空闲的 Arena，0 表示不限制
+	maxRetained int
+	// Arena 空闲多久之后被释放，0 表示不淘汰
+	maxIdleTime time.Duration
+	// 后台淘汰空闲 Arena 的间隔，0 表示不在后台淘汰
+	evictInterval time.Duration
+	stopEvict     chan struct{}
+	closeOnce     sync.Once
+
+	stats ArenaPoolStats
 }
 
-func NewArenaPool[T any]() *ArenaPool[T] {
-	return &ArenaPool[T]{}
+// ArenaPoolStats ArenaPool 的统计信息
+type ArenaPoolStats struct {
+	// Retained 当前保留的空闲 Arena 数
+	Retained int
+	// Hits 复用空闲 Arena 的次数
+	Hits int64
+	// News 新建 Arena 的次数
+	News int64
+	// Freed 因为超出 maxRetained 或者空闲超时而被释放的 Arena 数
+	Freed int64
 }
 
-func (a *ArenaPool[T]) Get() (*Arena[T], error) {
-	a.mutex.RLock()
-	l := len(a.chain)
-	a.mutex.RUnlock()
-	if l == 0 {
-		mem := arena.NewArena()
-		obj := arena.New[T](mem)
-		return &Arena[T]{arena: mem, obj: obj}, nil
+// NewArenaPool 创建一个 ArenaPool
+// 如果同时设置了 WithArenaMaxIdleTime 和 WithArenaEvictInterval，会启动一个后台协程定期淘汰空闲的 Arena，
+// 此时用完之后需要调用 Close 停止这个协程
+func NewArenaPool[T any](opts ...option.Option[ArenaPool[T]]) *ArenaPool[T] {
+	res := &ArenaPool[T]{}
+	option.Apply(res, opts...)
+	if res.maxIdleTime > 0 && res.evictInterval > 0 {
+		res.stopEvict = make(chan struct{})
+		go res.evictLoop()
+	}
+	return res
+}
+
+// WithMaxRetainedArenas 设置最多保留多少个空闲的 Arena，超出的部分在 Put 的时候直接释放
+func WithMaxRetainedArenas[T any](n int) option.Option[ArenaPool[T]] {
+	return func(a *ArenaPool[T]) {
+		a.maxRetained = n
+	}
+}
+
+// WithArenaMaxIdleTime 设置 Arena 的最大空闲时间
+// 空闲超时的 Arena 只会在 Get、Put 或者 Shrink 的时候被释放，
+// 所以如果 ArenaPool 可能长时间没有被使用，要么定期调用 Shrink，要么通过 WithArenaEvictInterval 开启后台淘汰
+func WithArenaMaxIdleTime[T any](d time.Duration) option.Option[ArenaPool[T]] {
+	return func(a *ArenaPool[T]) {
+		a.maxIdleTime = d
+	}
+}
+
+// WithArenaEvictInterval 设置后台淘汰空闲 Arena 的间隔，只有设置了 WithArenaMaxIdleTime 的时候才生效
+func WithArenaEvictInterval[T any](d time.Duration) option.Option[ArenaPool[T]] {
+	return func(a *ArenaPool[T]) {
+		a.evictInterval = d
 	}
+}
+
+func (a *ArenaPool[T]) Get() (*Arena[T], error) {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
-	l = len(a.chain)
+	a.evictIdle(time.Now())
+	l := len(a.chain)
 	if l == 0 {
-		mem := arena.NewArena()
-		obj := arena.New[T](mem)
-		return &Arena[T]{arena: mem, obj: obj}, nil
+		a.stats.News++
+		return newArena[T](), nil
 	}
 	ret := a.chain[l-1]
+	a.chain[l-1] = nil
 	a.chain = a.chain[:l-1]
+	a.stats.Hits++
 	return ret, nil
 }
 
 func (a *ArenaPool[T]) Put(X *Arena[T]) error {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
+	now := time.Now()
+	a.evictIdle(now)
+	if a.maxRetained > 0 && len(a.chain) >= a.maxRetained {
+		X.Free()
+		a.stats.Freed++
+		return nil
+	}
+	X.putAt = now
 	a.chain = append(a.chain, X)
 	return nil
 }
 
+// Shrink 释放所有空闲超时的 Arena
+// 如果 ArenaPool 长时间没有被使用，可以定期调用 Shrink 回收内存
+func (a *ArenaPool[T]) Shrink() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.evictIdle(time.Now())
+}
+
+// Close 停止后台淘汰协程，并释放所有空闲的 Arena
+// 多次调用 Close 是安全的，Close 之后不应该再使用 ArenaPool
+func (a *ArenaPool[T]) Close() {
+	a.closeOnce.Do(func() {
+		if a.stopEvict != nil {
+			close(a.stopEvict)
+		}
+	})
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for i, x := range a.chain {
+		x.Free()
+		a.chain[i] = nil
+	}
+	a.stats.Freed += int64(len(a.chain))
+	a.chain = a.chain[:0]
+}
+
+// Stats 返回 ArenaPool 的统计信息
+func (a *ArenaPool[T]) Stats() ArenaPoolStats {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	s := a.stats
+	s.Retained = len(a.chain)
+	return s
+}
+
+func (a *ArenaPool[T]) evictLoop() {
+	ticker := time.NewTicker(a.evictInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stopEvict:
+			return
+		case now := <-ticker.C:
+			a.mutex.Lock()
+			a.evictIdle(now)
+			a.mutex.Unlock()
+		}
+	}
+}
+
+// evictIdle 释放空闲超时的 Arena，必须在持有写锁的时候调用
+func (a *ArenaPool[T]) evictIdle(now time.Time) {
+	if a.maxIdleTime <= 0 {
+		return
+	}
+	// chain 按照放回的时间排序，越靠前越旧
+	n := 0
+	for n < len(a.chain) && now.Sub(a.chain[n].putAt) >= a.maxIdleTime {
+		a.chain[n].Free()
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	remain := copy(a.chain, a.chain[n:])
+	for i := remain; i < len(a.chain); i++ {
+		a.chain[i] = nil
+	}
+	a.chain = a.chain[:remain]
+	a.stats.Freed += int64(n)
+}
+
 // Arena 二次封装
-// 将来支持缩容，或者淘汰空闲很久的 Arena
 type Arena[T any] struct {
 	arena *arena.Arena
 	obj   *T
+	// 放回 ArenaPool 的时间
+	putAt time.Time
+}
+
+func newArena[T any]() *Arena[T] {
+	mem := arena.NewArena()
+	obj := arena.New[T](mem)
+	return &Arena[T]{arena: mem, obj: obj}
 }
 
 // Obj 返回已有的对象
 func (b *Arena[T]) Obj() *T {
 	return b.obj
 }
+
+// Reset 释放原本的内存，并重新分配一个零值的对象
+// 之前通过 Obj 拿到的对象都不能再使用
+func (b *Arena[T]) Reset() {
+	b.arena.Free()
+	b.arena = arena.NewArena()
+	b.obj = arena.New[T](b.arena)
+}
+
+// Free 释放内存，之后 Arena 不能再被使用，也不能放回 ArenaPool
+func (b *Arena[T]) Free() {
+	b.arena.Free()
+	b.obj = nil
+}
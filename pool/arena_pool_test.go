@@ -18,6 +18,7 @@ package pool
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -97,3 +98,83 @@ type TestStruct struct {
 	Age    int
 	AgePtr *int
 }
+
+func TestArenaPool_MaxRetained(t *testing.T) {
+	p := NewArenaPool[TestStruct](WithMaxRetainedArenas[TestStruct](1))
+	obj1, err := p.Get()
+	require.NoError(t, err)
+	obj2, err := p.Get()
+	require.NoError(t, err)
+
+	require.NoError(t, p.Put(obj1))
+	require.NoError(t, p.Put(obj2))
+	assert.Nil(t, obj2.Obj())
+
+	obj, err := p.Get()
+	require.NoError(t, err)
+	assert.Equal(t, obj1, obj)
+	assert.Equal(t, ArenaPoolStats{
+		Hits:  1,
+		News:  2,
+		Freed: 1,
+	}, p.Stats())
+}
+
+func TestArenaPool_MaxIdleTime(t *testing.T) {
+	p := NewArenaPool[TestStruct](WithArenaMaxIdleTime[TestStruct](10 * time.Millisecond))
+	obj1, err := p.Get()
+	require.NoError(t, err)
+	require.NoError(t, p.Put(obj1))
+	time.Sleep(20 * time.Millisecond)
+
+	obj2, err := p.Get()
+	require.NoError(t, err)
+	require.NoError(t, p.Put(obj2))
+	p.Shrink()
+	assert.Equal(t, 1, p.Stats().Retained)
+	assert.Nil(t, obj1.Obj())
+	assert.NotNil(t, obj2.Obj())
+
+	time.Sleep(20 * time.Millisecond)
+	p.Shrink()
+	assert.Equal(t, ArenaPoolStats{
+		News:  2,
+		Freed: 2,
+	}, p.Stats())
+}
+
+func TestArenaPool_EvictInterval(t *testing.T) {
+	p := NewArenaPool[TestStruct](
+		WithArenaMaxIdleTime[TestStruct](10*time.Millisecond),
+		WithArenaEvictInterval[TestStruct](5*time.Millisecond))
+	obj, err := p.Get()
+	require.NoError(t, err)
+	require.NoError(t, p.Put(obj))
+	// 不调用 Get、Put 和 Shrink，后台协程也会释放空闲超时的 Arena
+	assert.Eventually(t, func() bool {
+		return p.Stats().Freed == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Nil(t, obj.Obj())
+
+	obj, err = p.Get()
+	require.NoError(t, err)
+	require.NoError(t, p.Put(obj))
+	p.Close()
+	p.Close()
+	assert.Nil(t, obj.Obj())
+	assert.Equal(t, ArenaPoolStats{
+		News:  2,
+		Freed: 2,
+	}, p.Stats())
+}
+
+func TestArena_Reset(t *testing.T) {
+	p := NewArenaPool[TestStruct]()
+	obj, err := p.Get()
+	require.NoError(t, err)
+	obj.Obj().Age = 123
+	obj.Reset()
+	assert.Equal(t, &TestStruct{}, obj.Obj())
+	obj.Free()
+	assert.Nil(t, obj.Obj())
+}
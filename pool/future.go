@@ -0,0 +1,134 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Future 代表一个已经提交到 TaskPool 中的任务的执行结果
+type Future[T any] struct {
+	done chan struct{}
+	once sync.Once
+	val  T
+	err  error
+
+	mu        sync.Mutex
+	cancelled bool
+	// 任务开始执行之后才不为 nil
+	cancel context.CancelFunc
+}
+
+// SubmitFuture 将 fn 作为一个任务提交到 p 中，并且返回一个 Future 用于获取执行结果
+// fn 返回的 error 以及 fn 运行时的 panic 都会通过 Future.Get 返回给调用者
+// 提交失败时返回 nil 和对应的 error，语义与 TaskPool.Submit 一致
+// 如果任务没有执行就被丢弃，例如因为 ShutdownNow、DiscardPolicy 或者 DiscardOldestPolicy，那么 Get 返回 ErrTaskDiscarded
+func SubmitFuture[T any](ctx context.Context, p TaskPool, fn func(ctx context.Context) (T, error)) (*Future[T], error) {
+	if fn == nil {
+		return nil, fmt.Errorf("%w", errTaskIsInvalid)
+	}
+	f := &Future[T]{done: make(chan struct{})}
+	if err := p.Submit(ctx, &futureTask[T]{f: f, fn: fn}); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Get 等待任务执行完毕并返回结果
+// 如果在任务执行完毕之前 ctx 过期，那么返回 ctx.Err()，此时任务并不会被取消
+func (f *Future[T]) Get(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		var t T
+		return t, ctx.Err()
+	}
+}
+
+// Done 返回一个 chan，当任务执行完毕、被取消或者被丢弃时关闭
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Cancel 取消任务
+// 尚未开始执行的任务将不会再被执行，正在执行的任务会收到 ctx 取消的信号
+// 取消成功之后 Get 返回 context.Canceled
+// 如果任务已经执行完毕，那么返回 false
+func (f *Future[T]) Cancel() bool {
+	f.mu.Lock()
+	f.cancelled = true
+	cancel := f.cancel
+	f.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	var t T
+	return f.complete(t, context.Canceled)
+}
+
+func (f *Future[T]) complete(val T, err error) bool {
+	completed := false
+	f.once.Do(func() {
+		f.val, f.err = val, err
+		close(f.done)
+		completed = true
+	})
+	return completed
+}
+
+// futureTask 执行 fn 并将结果写回 Future
+type futureTask[T any] struct {
+	f  *Future[T]
+	fn func(ctx context.Context) (T, error)
+}
+
+func (t *futureTask[T]) Run(ctx context.Context) error {
+	t.f.mu.Lock()
+	if t.f.cancelled {
+		t.f.mu.Unlock()
+		return context.Canceled
+	}
+	select {
+	case <-t.f.done:
+		// 已经被丢弃
+		t.f.mu.Unlock()
+		return t.f.err
+	default:
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	t.f.cancel = cancel
+	t.f.mu.Unlock()
+	defer cancel()
+
+	var val T
+	// 复用 taskWrapper 将 panic 转化为 errTaskRunningPanic
+	tw := &taskWrapper{t: TaskFunc(func(ctx context.Context) error {
+		var err error
+		val, err = t.fn(ctx)
+		return err
+	})}
+	err := tw.Run(ctx)
+	t.f.complete(val, err)
+	return err
+}
+
+// discarded 任务没有执行就被丢弃，以 ErrTaskDiscarded 结束 Future
+func (t *futureTask[T]) discarded() {
+	var val T
+	t.f.complete(val, fmt.Errorf("%w", ErrTaskDiscarded))
+}
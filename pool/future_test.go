@@ -0,0 +1,245 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmitFuture(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		fn      func(ctx context.Context) (int, error)
+		wantVal int
+		wantErr error
+	}{
+		{
+			name: "返回结果",
+			fn: func(ctx context.Context) (int, error) {
+				return 123, nil
+			},
+			wantVal: 123,
+		},
+		{
+			name: "返回error",
+			fn: func(ctx context.Context) (int, error) {
+				return 0, errors.New("mock error")
+			},
+			wantErr: errors.New("mock error"),
+		},
+		{
+			name: "panic",
+			fn: func(ctx context.Context) (int, error) {
+				panic("mock panic")
+			},
+			wantErr: errTaskRunningPanic,
+		},
+	}
+
+	p := testNewRunningStateTaskPool(t, 1, 3)
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := SubmitFuture[int](context.Background(), p, tc.fn)
+			require.NoError(t, err)
+			<-f.Done()
+			val, err := f.Get(context.Background())
+			if tc.wantErr == errTaskRunningPanic {
+				assert.ErrorIs(t, err, errTaskRunningPanic)
+				return
+			}
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantVal, val)
+		})
+	}
+}
+
+func TestSubmitFuture_SubmitFailed(t *testing.T) {
+	t.Parallel()
+
+	_, err := SubmitFuture[int](context.Background(), testNewStoppedStateTaskPool(t, 1, 1), func(ctx context.Context) (int, error) {
+		return 0, nil
+	})
+	assert.ErrorIs(t, err, errTaskPoolIsStopped)
+
+	_, err = SubmitFuture[int](context.Background(), testNewRunningStateTaskPool(t, 1, 1), nil)
+	assert.ErrorIs(t, err, errTaskIsInvalid)
+}
+
+func TestFuture_Get_Timeout(t *testing.T) {
+	t.Parallel()
+
+	p := testNewRunningStateTaskPool(t, 1, 1)
+	wait := make(chan struct{})
+	f, err := SubmitFuture[int](context.Background(), p, func(ctx context.Context) (int, error) {
+		<-wait
+		return 1, nil
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = f.Get(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	close(wait)
+	val, err := f.Get(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, val)
+}
+
+func TestFuture_Cancel(t *testing.T) {
+	t.Parallel()
+
+	t.Run("取消正在执行的任务", func(t *testing.T) {
+		t.Parallel()
+		p := testNewRunningStateTaskPool(t, 1, 1)
+		running := make(chan struct{})
+		f, err := SubmitFuture[int](context.Background(), p, func(ctx context.Context) (int, error) {
+			close(running)
+			<-ctx.Done()
+			return 0, ctx.Err()
+		})
+		require.NoError(t, err)
+		<-running
+		assert.True(t, f.Cancel())
+		_, err = f.Get(context.Background())
+		assert.Equal(t, context.Canceled, err)
+	})
+
+	t.Run("取消尚未执行的任务", func(t *testing.T) {
+		t.Parallel()
+		p, err := NewOnDemandBlockTaskPool(1, 1)
+		require.NoError(t, err)
+		executed := false
+		f, err := SubmitFuture[int](context.Background(), p, func(ctx context.Context) (int, error) {
+			executed = true
+			return 1, nil
+		})
+		require.NoError(t, err)
+		assert.True(t, f.Cancel())
+		require.NoError(t, p.Start())
+		done, err := p.Shutdown()
+		require.NoError(t, err)
+		<-done
+		assert.False(t, executed)
+		_, err = f.Get(context.Background())
+		assert.Equal(t, context.Canceled, err)
+	})
+
+	t.Run("取消已经完成的任务", func(t *testing.T) {
+		t.Parallel()
+		p := testNewRunningStateTaskPool(t, 1, 1)
+		f, err := SubmitFuture[int](context.Background(), p, func(ctx context.Context) (int, error) {
+			return 1, nil
+		})
+		require.NoError(t, err)
+		<-f.Done()
+		assert.False(t, f.Cancel())
+		val, err := f.Get(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, val)
+	})
+}
+
+func TestFuture_Discarded(t *testing.T) {
+	t.Parallel()
+
+	fn := func(ctx context.Context) (int, error) {
+		return 1, nil
+	}
+	testCases := []struct {
+		name string
+		// 返回一个会被丢弃的 Future
+		discard func(t *testing.T) *Future[int]
+	}{
+		{
+			name: "ShutdownNow",
+			discard: func(t *testing.T) *Future[int] {
+				p := testNewRunningStateTaskPool(t, 1, 1)
+				running := make(chan struct{})
+				require.NoError(t, p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error {
+					close(running)
+					<-ctx.Done()
+					return ctx.Err()
+				})))
+				<-running
+				f, err := SubmitFuture[int](context.Background(), p, fn)
+				require.NoError(t, err)
+				_, err = p.ShutdownNow()
+				require.NoError(t, err)
+				return f
+			},
+		},
+		{
+			name: "DiscardPolicy",
+			discard: func(t *testing.T) *Future[int] {
+				// 未启动的 TaskPool 中队列满了之后不会有任务被取走
+				p, err := NewOnDemandBlockTaskPool(1, 1, WithRejectPolicy(DiscardPolicy{}))
+				require.NoError(t, err)
+				_, err = SubmitFuture[int](context.Background(), p, fn)
+				require.NoError(t, err)
+				f, err := SubmitFuture[int](context.Background(), p, fn)
+				require.NoError(t, err)
+				return f
+			},
+		},
+		{
+			name: "DiscardOldestPolicy",
+			discard: func(t *testing.T) *Future[int] {
+				p, err := NewOnDemandBlockTaskPool(1, 1, WithRejectPolicy(DiscardOldestPolicy{}))
+				require.NoError(t, err)
+				f, err := SubmitFuture[int](context.Background(), p, fn)
+				require.NoError(t, err)
+				_, err = SubmitFuture[int](context.Background(), p, fn)
+				require.NoError(t, err)
+				return f
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			f := tc.discard(t)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			_, err := f.Get(ctx)
+			assert.ErrorIs(t, err, ErrTaskDiscarded)
+			assert.False(t, f.Cancel())
+		})
+	}
+}
+
+func ExampleSubmitFuture() {
+	p, _ := NewOnDemandBlockTaskPool(1, 10)
+	_ = p.Start()
+	f, _ := SubmitFuture[string](context.Background(), p, func(ctx context.Context) (string, error) {
+		return "hello, world", nil
+	})
+	val, err := f.Get(context.Background())
+	fmt.Println(val, err)
+	// Output:
+	// hello, world <nil>
+}
@@ -0,0 +1,176 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// KeyedTaskPool 按照 key 串行执行任务
+// key 相同的任务按照提交顺序依次执行，key 不同的任务在 TaskPool 中并发执行
+// 同一个 key 的任务会在同一次 TaskPool 调度中被依次执行完毕，所以同一时刻每个 key 最多占用一个工作协程
+// 如果执行某个 key 任务的 drainer 被底层的 TaskPool 丢弃，例如因为 ShutdownNow 或者 DiscardPolicy，
+// 那么该 key 下等待中的任务也会被丢弃，之后提交的任务会重新向 TaskPool 提交 drainer
+// 注意：底层的 TaskPool 不能通过自定义的 RejectPolicy 静默丢弃任务，否则对应 key 的任务将不会再被执行
+type KeyedTaskPool[K comparable] struct {
+	pool       TaskPool
+	maxBacklog int
+
+	mutex  sync.Mutex
+	queues map[K]*keyedQueue
+}
+
+// keyedQueue 是某个 key 下等待执行的任务
+type keyedQueue struct {
+	tasks []Task
+	// 是否已经有负责执行该 key 任务的 drainer
+	active bool
+	// 是否正在向 TaskPool 提交 drainer
+	starting bool
+	// 队列有变化时关闭 signal 唤醒等待者
+	signal chan struct{}
+}
+
+func (q *keyedQueue) broadcast() {
+	close(q.signal)
+	q.signal = make(chan struct{})
+}
+
+// NewKeyedTaskPool 创建一个 KeyedTaskPool
+// maxBacklog 是每个 key 最多有多少个任务在等待执行，不包括正在执行的任务
+func NewKeyedTaskPool[K comparable](p TaskPool, maxBacklog int) (*KeyedTaskPool[K], error) {
+	if maxBacklog < 1 {
+		return nil, fmt.Errorf("%w：maxBacklog应该大于0", errInvalidArgument)
+	}
+	return &KeyedTaskPool[K]{
+		pool:       p,
+		maxBacklog: maxBacklog,
+		queues:     make(map[K]*keyedQueue),
+	}, nil
+}
+
+// Submit 提交一个 key 为 key 的任务
+// 如果该 key 等待执行的任务已经达到 maxBacklog，那么会阻塞直到 ctx 过期，并返回 ctx.Err()
+// 其余语义与 TaskPool.Submit 一致
+func (k *KeyedTaskPool[K]) Submit(ctx context.Context, key K, task Task) error {
+	if task == nil {
+		return fmt.Errorf("%w", errTaskIsInvalid)
+	}
+	for {
+		k.mutex.Lock()
+		q, ok := k.queues[key]
+		if !ok {
+			q = &keyedQueue{signal: make(chan struct{})}
+			k.queues[key] = q
+		}
+		if q.starting || len(q.tasks) >= k.maxBacklog {
+			signal := q.signal
+			k.mutex.Unlock()
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-signal:
+				continue
+			}
+		}
+		q.tasks = append(q.tasks, task)
+		if q.active {
+			k.mutex.Unlock()
+			return nil
+		}
+		// 当前没有 drainer，需要向 TaskPool 提交一个
+		// 提交期间同一个 key 的其它任务需要等待，避免提交失败之后它们无人执行
+		q.active, q.starting = true, true
+		k.mutex.Unlock()
+		return k.startDrainer(ctx, key, q)
+	}
+}
+
+func (k *KeyedTaskPool[K]) startDrainer(ctx context.Context, key K, q *keyedQueue) error {
+	err := k.pool.Submit(ctx, &keyedDrainer[K]{k: k, key: key, q: q})
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	q.starting = false
+	if err != nil {
+		// drainer 没有提交成功，此时队列中只有当前的任务
+		q.tasks, q.active = nil, false
+	}
+	if !q.active && len(q.tasks) == 0 {
+		delete(k.queues, key)
+	}
+	q.broadcast()
+	return err
+}
+
+// drain 依次执行 key 下的所有任务，直到队列为空
+func (k *KeyedTaskPool[K]) drain(ctx context.Context, key K, q *keyedQueue) {
+	for {
+		k.mutex.Lock()
+		if len(q.tasks) == 0 {
+			q.active = false
+			// 仍在提交中的话由 startDrainer 负责清理
+			if !q.starting {
+				delete(k.queues, key)
+			}
+			k.mutex.Unlock()
+			return
+		}
+		task := q.tasks[0]
+		q.tasks[0] = nil
+		q.tasks = q.tasks[1:]
+		q.broadcast()
+		k.mutex.Unlock()
+		// 复用 taskWrapper，避免一个任务 panic 导致后续任务无法执行
+		_ = (&taskWrapper{t: task}).Run(ctx)
+	}
+}
+
+// keyedDrainer 是提交到 TaskPool 中负责执行某个 key 任务的任务
+type keyedDrainer[K comparable] struct {
+	k   *KeyedTaskPool[K]
+	key K
+	q   *keyedQueue
+	// 被 TaskPool 丢弃之后为 true，受 k.mutex 保护
+	dropped bool
+}
+
+func (d *keyedDrainer[K]) Run(ctx context.Context) error {
+	d.k.mutex.Lock()
+	dropped := d.dropped
+	d.k.mutex.Unlock()
+	if dropped {
+		return fmt.Errorf("%w", ErrTaskDiscarded)
+	}
+	d.k.drain(ctx, d.key, d.q)
+	return nil
+}
+
+// discarded drainer 没有执行就被丢弃，此时该 key 下等待中的任务也无人执行，一并丢弃
+func (d *keyedDrainer[K]) discarded() {
+	k, q := d.k, d.q
+	k.mutex.Lock()
+	d.dropped = true
+	tasks := q.tasks
+	q.tasks, q.active = nil, false
+	// 仍在提交中的话由 startDrainer 负责清理
+	if !q.starting && k.queues[d.key] == q {
+		delete(k.queues, d.key)
+	}
+	q.broadcast()
+	k.mutex.Unlock()
+	notifyDiscarded(tasks...)
+}
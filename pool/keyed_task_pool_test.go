@@ -0,0 +1,189 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKeyedTaskPool(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewKeyedTaskPool[string](testNewRunningStateTaskPool(t, 1, 1), 0)
+	assert.ErrorIs(t, err, errInvalidArgument)
+}
+
+func TestKeyedTaskPool_Submit(t *testing.T) {
+	t.Parallel()
+
+	p := testNewRunningStateTaskPool(t, 4, 100)
+	kp, err := NewKeyedTaskPool[int](p, 100)
+	require.NoError(t, err)
+
+	const keys, n = 4, 50
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	running := make(map[int]bool, keys)
+	res := make(map[int][]int, keys)
+	for i := 0; i < n; i++ {
+		for key := 0; key < keys; key++ {
+			key, i := key, i
+			wg.Add(1)
+			err = kp.Submit(context.Background(), key, TaskFunc(func(ctx context.Context) error {
+				defer wg.Done()
+				mutex.Lock()
+				assert.False(t, running[key], "同一个 key 的任务不能并发执行")
+				running[key] = true
+				mutex.Unlock()
+
+				time.Sleep(time.Microsecond)
+
+				mutex.Lock()
+				running[key] = false
+				res[key] = append(res[key], i)
+				mutex.Unlock()
+				if i%10 == 0 {
+					panic("mock panic")
+				}
+				return nil
+			}))
+			require.NoError(t, err)
+		}
+	}
+	wg.Wait()
+
+	want := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		want = append(want, i)
+	}
+	for key := 0; key < keys; key++ {
+		assert.Equal(t, want, res[key])
+	}
+	assert.Eventually(t, func() bool {
+		kp.mutex.Lock()
+		defer kp.mutex.Unlock()
+		return len(kp.queues) == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestKeyedTaskPool_Backlog(t *testing.T) {
+	t.Parallel()
+
+	p := testNewRunningStateTaskPool(t, 2, 10)
+	kp, err := NewKeyedTaskPool[string](p, 1)
+	require.NoError(t, err)
+
+	wait := make(chan struct{})
+	running := make(chan struct{})
+	require.NoError(t, kp.Submit(context.Background(), "a", TaskFunc(func(ctx context.Context) error {
+		close(running)
+		<-wait
+		return nil
+	})))
+	<-running
+	// 正在执行的任务不占用 backlog
+	require.NoError(t, kp.Submit(context.Background(), "a", TaskFunc(func(ctx context.Context) error { return nil })))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	err = kp.Submit(ctx, "a", TaskFunc(func(ctx context.Context) error { return nil }))
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// 其它 key 不受影响
+	done := make(chan struct{})
+	require.NoError(t, kp.Submit(context.Background(), "b", TaskFunc(func(ctx context.Context) error {
+		close(done)
+		return nil
+	})))
+	<-done
+	close(wait)
+}
+
+func TestKeyedTaskPool_SubmitFailed(t *testing.T) {
+	t.Parallel()
+
+	kp, err := NewKeyedTaskPool[string](testNewStoppedStateTaskPool(t, 1, 1), 1)
+	require.NoError(t, err)
+	assert.ErrorIs(t, kp.Submit(context.Background(), "a", nil), errTaskIsInvalid)
+	err = kp.Submit(context.Background(), "a", TaskFunc(func(ctx context.Context) error { return nil }))
+	assert.ErrorIs(t, err, errTaskPoolIsStopped)
+	assert.Equal(t, 0, len(kp.queues))
+}
+
+func TestKeyedTaskPool_DrainerDiscarded(t *testing.T) {
+	t.Parallel()
+
+	p := testNewRunningStateTaskPool(t, 1, 10)
+	kp, err := NewKeyedTaskPool[string](p, 10)
+	require.NoError(t, err)
+
+	running := make(chan struct{})
+	require.NoError(t, p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error {
+		close(running)
+		<-ctx.Done()
+		return ctx.Err()
+	})))
+	<-running
+	// 唯一的工作协程被占用，key a 的 drainer 只能在队列中等待
+	var cnt int32
+	task := TaskFunc(func(ctx context.Context) error {
+		atomic.AddInt32(&cnt, 1)
+		return nil
+	})
+	require.NoError(t, kp.Submit(context.Background(), "a", task))
+	require.NoError(t, kp.Submit(context.Background(), "a", task))
+
+	tasks, err := p.ShutdownNow()
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(tasks))
+	kp.mutex.Lock()
+	assert.Equal(t, 0, len(kp.queues))
+	kp.mutex.Unlock()
+
+	// drainer 被丢弃之后，提交任务会重新提交 drainer，而不是放入无人执行的队列
+	err = kp.Submit(context.Background(), "a", task)
+	assert.ErrorIs(t, err, errTaskPoolIsStopped)
+	assert.ErrorIs(t, tasks[0].Run(context.Background()), ErrTaskDiscarded)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&cnt))
+}
+
+func ExampleKeyedTaskPool() {
+	p, _ := NewOnDemandBlockTaskPool(4, 100)
+	_ = p.Start()
+	kp, _ := NewKeyedTaskPool[string](p, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		i := i
+		wg.Add(1)
+		_ = kp.Submit(context.Background(), "user-1", TaskFunc(func(ctx context.Context) error {
+			defer wg.Done()
+			fmt.Println("event", i)
+			return nil
+		}))
+	}
+	wg.Wait()
+	// Output:
+	// event 0
+	// event 1
+	// event 2
+}
@@ -0,0 +1,322 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ecodeclub/ekit/bean/option"
+)
+
+var errObjectPoolClosed = errors.New("ekit: ObjectPool已关闭")
+
+const defaultMaxIdleObjects = 8
+
+// ObjectPool 有界的对象池，适用于连接、子进程这类创建代价高并且需要显式销毁的对象
+// 与 syncx.Pool 不同，ObjectPool 中的对象不会被 GC 回收，只会因为空闲超时、校验失败或者超出 maxIdle 被销毁
+type ObjectPool[T any] struct {
+	factory  func(ctx context.Context) (T, error)
+	destroy  func(t T)
+	validate func(t T) bool
+
+	// 最多保留多少个空闲对象
+	maxIdle int
+	// maxIdle 是否由用户设置，没有设置时默认值不超过 maxTotal
+	maxIdleSet bool
+	// 最多有多少个对象，包括借出的和空闲的，0 表示不限制
+	maxTotal int
+	// 空闲多久之后被销毁，0 表示不淘汰
+	idleTimeout time.Duration
+
+	mutex sync.Mutex
+	// 按照归还时间排序，越靠后越新
+	idle []idleObject[T]
+	// 借出的、空闲的以及正在创建的对象总数
+	total int
+	// 有对象归还或者销毁时关闭 signal 唤醒等待者
+	signal chan struct{}
+	closed bool
+	stats  ObjectPoolStats
+
+	stopEvict chan struct{}
+}
+
+type idleObject[T any] struct {
+	obj      T
+	returnAt time.Time
+}
+
+// ObjectPoolStats ObjectPool 的统计信息
+type ObjectPoolStats struct {
+	// Total 借出的、空闲的以及正在创建的对象总数
+	Total int
+	// Idle 空闲的对象数
+	Idle int
+	// Created 创建的对象总数
+	Created int64
+	// Destroyed 销毁的对象总数
+	Destroyed int64
+	// Hits 从空闲对象中借出的次数
+	Hits int64
+	// Waits 因为达到 maxTotal 而等待的次数
+	Waits int64
+	// ValidateFailures 借出时校验失败的次数
+	ValidateFailures int64
+	// Evicted 因为空闲超时被销毁的对象数
+	Evicted int64
+}
+
+// NewObjectPool 创建一个 ObjectPool
+// factory 用于创建新的对象，ctx 是 Borrow 传入的 ctx
+// 默认最多保留 8 个空闲对象（设置了更小的 maxTotal 时为 maxTotal），不限制对象总数，也不淘汰空闲对象
+func NewObjectPool[T any](factory func(ctx context.Context) (T, error), opts ...option.Option[ObjectPool[T]]) (*ObjectPool[T], error) {
+	if factory == nil {
+		return nil, fmt.Errorf("%w：factory不能为nil", errInvalidArgument)
+	}
+	p := &ObjectPool[T]{
+		factory: factory,
+		maxIdle: defaultMaxIdleObjects,
+		signal:  make(chan struct{}),
+	}
+	option.Apply(p, opts...)
+	if p.maxIdle < 0 || p.maxTotal < 0 || p.idleTimeout < 0 {
+		return nil, fmt.Errorf("%w：maxIdle，maxTotal和idleTimeout都不能小于0", errInvalidArgument)
+	}
+	if !p.maxIdleSet && p.maxTotal > 0 && p.maxIdle > p.maxTotal {
+		p.maxIdle = p.maxTotal
+	}
+	if p.maxTotal > 0 && p.maxIdle > p.maxTotal {
+		return nil, fmt.Errorf("%w：maxIdle不能大于maxTotal", errInvalidArgument)
+	}
+	if p.idleTimeout > 0 {
+		p.stopEvict = make(chan struct{})
+		go p.evictLoop()
+	}
+	return p, nil
+}
+
+// WithMaxIdleObjects 设置最多保留多少个空闲对象
+func WithMaxIdleObjects[T any](n int) option.Option[ObjectPool[T]] {
+	return func(p *ObjectPool[T]) {
+		p.maxIdle = n
+		p.maxIdleSet = true
+	}
+}
+
+// WithMaxTotalObjects 设置最多有多少个对象，包括借出的和空闲的
+// 达到上限之后 Borrow 会阻塞，直到有对象被归还或者销毁
+func WithMaxTotalObjects[T any](n int) option.Option[ObjectPool[T]] {
+	return func(p *ObjectPool[T]) {
+		p.maxTotal = n
+	}
+}
+
+// WithObjectIdleTimeout 设置空闲对象的超时时间，超时的空闲对象会被后台协程销毁
+func WithObjectIdleTimeout[T any](d time.Duration) option.Option[ObjectPool[T]] {
+	return func(p *ObjectPool[T]) {
+		p.idleTimeout = d
+	}
+}
+
+// WithObjectDestroyer 设置销毁对象的回调，例如关闭连接
+func WithObjectDestroyer[T any](destroy func(t T)) option.Option[ObjectPool[T]] {
+	return func(p *ObjectPool[T]) {
+		p.destroy = destroy
+	}
+}
+
+// WithObjectValidator 设置借出时的校验方法，校验失败的对象会被销毁
+func WithObjectValidator[T any](validate func(t T) bool) option.Option[ObjectPool[T]] {
+	return func(p *ObjectPool[T]) {
+		p.validate = validate
+	}
+}
+
+// Borrow 借出一个对象
+// 优先借出最近归还的空闲对象，没有空闲对象时创建新对象
+// 如果对象总数已经达到 maxTotal，那么会阻塞直到有对象被归还，或者 ctx 过期
+// 借出的对象必须通过 Return 或者 Invalidate 交还给 ObjectPool
+func (p *ObjectPool[T]) Borrow(ctx context.Context) (T, error) {
+	var zero T
+	for {
+		p.mutex.Lock()
+		if p.closed {
+			p.mutex.Unlock()
+			return zero, fmt.Errorf("%w", errObjectPoolClosed)
+		}
+		if l := len(p.idle); l > 0 {
+			obj := p.idle[l-1].obj
+			p.idle[l-1] = idleObject[T]{}
+			p.idle = p.idle[:l-1]
+			p.mutex.Unlock()
+			if p.validate != nil && !p.validate(obj) {
+				p.mutex.Lock()
+				p.stats.ValidateFailures++
+				p.mutex.Unlock()
+				p.Invalidate(obj)
+				continue
+			}
+			p.mutex.Lock()
+			p.stats.Hits++
+			p.mutex.Unlock()
+			return obj, nil
+		}
+		if p.maxTotal == 0 || p.total < p.maxTotal {
+			p.total++
+			p.mutex.Unlock()
+			obj, err := p.factory(ctx)
+			p.mutex.Lock()
+			if err != nil {
+				p.total--
+				p.broadcast()
+				p.mutex.Unlock()
+				return zero, err
+			}
+			p.stats.Created++
+			p.mutex.Unlock()
+			return obj, nil
+		}
+		p.stats.Waits++
+		signal := p.signal
+		p.mutex.Unlock()
+		select {
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		case <-signal:
+		}
+	}
+}
+
+// Return 归还一个对象
+// 如果空闲对象已经达到 maxIdle，或者 ObjectPool 已经关闭，那么对象会被销毁
+func (p *ObjectPool[T]) Return(obj T) {
+	p.mutex.Lock()
+	if p.closed || len(p.idle) >= p.maxIdle {
+		p.mutex.Unlock()
+		p.Invalidate(obj)
+		return
+	}
+	p.idle = append(p.idle, idleObject[T]{obj: obj, returnAt: time.Now()})
+	p.broadcast()
+	p.mutex.Unlock()
+}
+
+// Invalidate 销毁一个借出的对象，例如发现连接已经断开
+func (p *ObjectPool[T]) Invalidate(obj T) {
+	p.mutex.Lock()
+	p.total--
+	p.stats.Destroyed++
+	p.broadcast()
+	p.mutex.Unlock()
+	if p.destroy != nil {
+		p.destroy(obj)
+	}
+}
+
+// Stats 返回 ObjectPool 的统计信息
+func (p *ObjectPool[T]) Stats() ObjectPoolStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	s := p.stats
+	s.Total = p.total
+	s.Idle = len(p.idle)
+	return s
+}
+
+// Close 关闭 ObjectPool 并销毁所有空闲对象
+// 之后 Borrow 会返回错误，借出的对象在归还时会被销毁
+func (p *ObjectPool[T]) Close() error {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return fmt.Errorf("%w", errObjectPoolClosed)
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.total -= len(idle)
+	p.stats.Destroyed += int64(len(idle))
+	p.broadcast()
+	p.mutex.Unlock()
+
+	if p.stopEvict != nil {
+		close(p.stopEvict)
+	}
+	p.destroyAll(idle)
+	return nil
+}
+
+// broadcast 唤醒所有等待者，必须在持有锁的时候调用
+func (p *ObjectPool[T]) broadcast() {
+	close(p.signal)
+	p.signal = make(chan struct{})
+}
+
+func (p *ObjectPool[T]) destroyAll(objs []idleObject[T]) {
+	if p.destroy == nil {
+		return
+	}
+	for _, o := range objs {
+		p.destroy(o.obj)
+	}
+}
+
+func (p *ObjectPool[T]) evictLoop() {
+	interval := p.idleTimeout / 2
+	if interval <= 0 {
+		interval = p.idleTimeout
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stopEvict:
+			return
+		case now := <-ticker.C:
+			p.evict(now)
+		}
+	}
+}
+
+// evict 销毁空闲超时的对象
+func (p *ObjectPool[T]) evict(now time.Time) {
+	p.mutex.Lock()
+	// p.idle 按照归还时间排序，所以超时的对象都在前面
+	n := 0
+	for n < len(p.idle) && now.Sub(p.idle[n].returnAt) >= p.idleTimeout {
+		n++
+	}
+	if n == 0 {
+		p.mutex.Unlock()
+		return
+	}
+	evicted := make([]idleObject[T], n)
+	copy(evicted, p.idle[:n])
+	remain := copy(p.idle, p.idle[n:])
+	for i := remain; i < len(p.idle); i++ {
+		p.idle[i] = idleObject[T]{}
+	}
+	p.idle = p.idle[:remain]
+	p.total -= n
+	p.stats.Destroyed += int64(n)
+	p.stats.Evicted += int64(n)
+	p.broadcast()
+	p.mutex.Unlock()
+	p.destroyAll(evicted)
+}
@@ -0,0 +1,237 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ekit/bean/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testConn struct {
+	id     int32
+	closed atomic.Bool
+}
+
+type testConnFactory struct {
+	id        int32
+	destroyed int32
+}
+
+func (f *testConnFactory) create(ctx context.Context) (*testConn, error) {
+	return &testConn{id: atomic.AddInt32(&f.id, 1)}, nil
+}
+
+func (f *testConnFactory) destroy(c *testConn) {
+	c.closed.Store(true)
+	atomic.AddInt32(&f.destroyed, 1)
+}
+
+func TestNewObjectPool(t *testing.T) {
+	t.Parallel()
+
+	f := &testConnFactory{}
+	testCases := []struct {
+		name    string
+		factory func(ctx context.Context) (*testConn, error)
+		opts    []option.Option[ObjectPool[*testConn]]
+	}{
+		{
+			name: "factory为nil",
+		},
+		{
+			name:    "maxIdle小于0",
+			factory: f.create,
+			opts:    []option.Option[ObjectPool[*testConn]]{WithMaxIdleObjects[*testConn](-1)},
+		},
+		{
+			name:    "maxIdle大于maxTotal",
+			factory: f.create,
+			opts: []option.Option[ObjectPool[*testConn]]{
+				WithMaxIdleObjects[*testConn](3),
+				WithMaxTotalObjects[*testConn](2),
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewObjectPool[*testConn](tc.factory, tc.opts...)
+			assert.ErrorIs(t, err, errInvalidArgument)
+		})
+	}
+}
+
+func TestNewObjectPool_DefaultMaxIdle(t *testing.T) {
+	t.Parallel()
+
+	f := &testConnFactory{}
+	p, err := NewObjectPool[*testConn](f.create)
+	require.NoError(t, err)
+	assert.Equal(t, defaultMaxIdleObjects, p.maxIdle)
+
+	// 没有设置 maxIdle 时，默认值不超过 maxTotal
+	p, err = NewObjectPool[*testConn](f.create, WithMaxTotalObjects[*testConn](4))
+	require.NoError(t, err)
+	assert.Equal(t, 4, p.maxIdle)
+
+	p, err = NewObjectPool[*testConn](f.create,
+		WithMaxTotalObjects[*testConn](4), WithMaxIdleObjects[*testConn](2))
+	require.NoError(t, err)
+	assert.Equal(t, 2, p.maxIdle)
+}
+
+func TestObjectPool_BorrowAndReturn(t *testing.T) {
+	t.Parallel()
+
+	f := &testConnFactory{}
+	p, err := NewObjectPool[*testConn](f.create,
+		WithMaxIdleObjects[*testConn](1),
+		WithObjectDestroyer[*testConn](f.destroy))
+	require.NoError(t, err)
+
+	c1, err := p.Borrow(context.Background())
+	require.NoError(t, err)
+	c2, err := p.Borrow(context.Background())
+	require.NoError(t, err)
+	assert.NotEqual(t, c1.id, c2.id)
+
+	p.Return(c1)
+	// 超出 maxIdle 的对象被销毁
+	p.Return(c2)
+	assert.True(t, c2.closed.Load())
+
+	c3, err := p.Borrow(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, c1, c3)
+	p.Return(c3)
+
+	assert.Equal(t, ObjectPoolStats{
+		Total:     1,
+		Idle:      1,
+		Created:   2,
+		Destroyed: 1,
+		Hits:      1,
+	}, p.Stats())
+
+	require.NoError(t, p.Close())
+	assert.True(t, c1.closed.Load())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&f.destroyed))
+	_, err = p.Borrow(context.Background())
+	assert.ErrorIs(t, err, errObjectPoolClosed)
+	assert.ErrorIs(t, p.Close(), errObjectPoolClosed)
+}
+
+func TestObjectPool_MaxTotal(t *testing.T) {
+	t.Parallel()
+
+	f := &testConnFactory{}
+	p, err := NewObjectPool[*testConn](f.create,
+		WithMaxIdleObjects[*testConn](1),
+		WithMaxTotalObjects[*testConn](1))
+	require.NoError(t, err)
+
+	c1, err := p.Borrow(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = p.Borrow(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c, err := p.Borrow(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, c1, c)
+	}()
+	time.Sleep(time.Millisecond)
+	p.Return(c1)
+	wg.Wait()
+	assert.True(t, p.Stats().Waits >= 1)
+}
+
+func TestObjectPool_Validate(t *testing.T) {
+	t.Parallel()
+
+	f := &testConnFactory{}
+	p, err := NewObjectPool[*testConn](f.create,
+		WithObjectDestroyer[*testConn](f.destroy),
+		WithObjectValidator[*testConn](func(c *testConn) bool {
+			return c.id != 1
+		}))
+	require.NoError(t, err)
+
+	c1, err := p.Borrow(context.Background())
+	require.NoError(t, err)
+	p.Return(c1)
+
+	c2, err := p.Borrow(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), c2.id)
+	assert.True(t, c1.closed.Load())
+	stats := p.Stats()
+	assert.Equal(t, int64(1), stats.ValidateFailures)
+	assert.Equal(t, 1, stats.Total)
+}
+
+func TestObjectPool_FactoryError(t *testing.T) {
+	t.Parallel()
+
+	errMock := errors.New("mock error")
+	p, err := NewObjectPool[int](func(ctx context.Context) (int, error) {
+		return 0, errMock
+	}, WithMaxTotalObjects[int](1), WithMaxIdleObjects[int](1))
+	require.NoError(t, err)
+	_, err = p.Borrow(context.Background())
+	assert.Equal(t, errMock, err)
+	assert.Equal(t, 0, p.Stats().Total)
+}
+
+func TestObjectPool_IdleTimeout(t *testing.T) {
+	t.Parallel()
+
+	f := &testConnFactory{}
+	p, err := NewObjectPool[*testConn](f.create,
+		WithObjectDestroyer[*testConn](f.destroy),
+		WithObjectIdleTimeout[*testConn](10*time.Millisecond))
+	require.NoError(t, err)
+	defer func() {
+		_ = p.Close()
+	}()
+
+	c1, err := p.Borrow(context.Background())
+	require.NoError(t, err)
+	c2, err := p.Borrow(context.Background())
+	require.NoError(t, err)
+	p.Return(c1)
+	p.Return(c2)
+
+	assert.Eventually(t, func() bool {
+		return c1.closed.Load() && c2.closed.Load()
+	}, time.Second, time.Millisecond)
+	stats := p.Stats()
+	assert.Equal(t, int64(2), stats.Evicted)
+	assert.Equal(t, 0, stats.Total)
+}
@@ -0,0 +1,72 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"time"
+
+	"github.com/ecodeclub/ekit/bean/option"
+)
+
+var _ Observer = NopObserver{}
+
+// Observer 用于观测 OnDemandBlockTaskPool 内部发生的事件，一般用于接入监控
+// 所有的方法都是在 TaskPool 内部同步调用的，实现者不能阻塞，也需要自己保证并发安全
+// 方法中的 task 都是用户提交的原始任务
+type Observer interface {
+	// OnTaskSubmitted 任务被放入了队列
+	OnTaskSubmitted(task Task)
+	// OnTaskStarted 任务开始执行，waitTime 是从调用 Submit 到开始执行的时间
+	OnTaskStarted(task Task, waitTime time.Duration)
+	// OnTaskFinished 任务执行结束，runTime 是执行耗时
+	// 如果任务 panic 了，那么 err 是由 panic 转化而来的 error
+	OnTaskFinished(task Task, runTime time.Duration, err error)
+	// OnTaskPanicked 任务执行时发生了 panic，val 是 recover 的返回值
+	// 随后依旧会调用 OnTaskFinished
+	OnTaskPanicked(task Task, val any)
+	// OnTaskRejected 任务被拒绝策略处理，
+	// 对于 DiscardOldestPolicy 来说，task 是被丢弃的旧任务
+	OnTaskRejected(task Task)
+	// OnGoroutineCreated 创建了一个工作协程
+	OnGoroutineCreated(id int)
+	// OnGoroutineReclaimed 一个工作协程退出了
+	OnGoroutineReclaimed(id int)
+}
+
+// NopObserver 什么也不做的 Observer
+// 组合 NopObserver 之后就只需要实现关心的方法
+type NopObserver struct{}
+
+func (NopObserver) OnTaskSubmitted(task Task) {}
+
+func (NopObserver) OnTaskStarted(task Task, waitTime time.Duration) {}
+
+func (NopObserver) OnTaskFinished(task Task, runTime time.Duration, err error) {}
+
+func (NopObserver) OnTaskPanicked(task Task, val any) {}
+
+func (NopObserver) OnTaskRejected(task Task) {}
+
+func (NopObserver) OnGoroutineCreated(id int) {}
+
+func (NopObserver) OnGoroutineReclaimed(id int) {}
+
+// WithObserver 设置 Observer
+// 没有设置 Observer 的时候，TaskPool 不会为观测产生任何额外的开销
+func WithObserver(observer Observer) option.Option[OnDemandBlockTaskPool] {
+	return func(pool *OnDemandBlockTaskPool) {
+		pool.observer = observer
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordObserver struct {
+	NopObserver
+	mutex      sync.Mutex
+	submitted  int
+	started    int
+	finished   int
+	errs       []error
+	panicked   []any
+	rejected   int
+	created    int
+	reclaimed  int
+	waitTimes  []time.Duration
+	runTimes   []time.Duration
+	lastFinish Task
+}
+
+func (r *recordObserver) OnTaskSubmitted(task Task) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.submitted++
+}
+
+func (r *recordObserver) OnTaskStarted(task Task, waitTime time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.started++
+	r.waitTimes = append(r.waitTimes, waitTime)
+}
+
+func (r *recordObserver) OnTaskFinished(task Task, runTime time.Duration, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.finished++
+	r.runTimes = append(r.runTimes, runTime)
+	r.lastFinish = task
+	if err != nil {
+		r.errs = append(r.errs, err)
+	}
+}
+
+func (r *recordObserver) OnTaskPanicked(task Task, val any) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.panicked = append(r.panicked, val)
+}
+
+func (r *recordObserver) OnTaskRejected(task Task) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.rejected++
+}
+
+func (r *recordObserver) OnGoroutineCreated(id int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.created++
+}
+
+func (r *recordObserver) OnGoroutineReclaimed(id int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.reclaimed++
+}
+
+func TestOnDemandBlockTaskPool_Observer(t *testing.T) {
+	t.Parallel()
+
+	o := &recordObserver{}
+	p, err := NewOnDemandBlockTaskPool(2, 3, WithObserver(o), WithRejectPolicy(AbortPolicy{}))
+	require.NoError(t, err)
+
+	errMock := errors.New("mock error")
+	tasks := []TaskFunc{
+		func(ctx context.Context) error {
+			time.Sleep(time.Millisecond)
+			return nil
+		},
+		func(ctx context.Context) error { return errMock },
+		func(ctx context.Context) error { panic("mock panic") },
+	}
+	for _, task := range tasks {
+		require.NoError(t, p.Submit(context.Background(), task))
+	}
+	err = p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error { return nil }))
+	assert.ErrorIs(t, err, ErrTaskRejected)
+
+	time.Sleep(time.Millisecond)
+	require.NoError(t, p.Start())
+	done, err := p.Shutdown()
+	require.NoError(t, err)
+	<-done
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	assert.Equal(t, 3, o.submitted)
+	assert.Equal(t, 3, o.started)
+	assert.Equal(t, 3, o.finished)
+	assert.Equal(t, 1, o.rejected)
+	assert.Equal(t, []any{"mock panic"}, o.panicked)
+	assert.Equal(t, 2, len(o.errs))
+	assert.Equal(t, 2, o.created)
+	assert.Equal(t, 2, o.reclaimed)
+	for _, waitTime := range o.waitTimes {
+		assert.True(t, waitTime >= time.Millisecond)
+	}
+	// 回调中的是用户提交的原始任务
+	_, ok := o.lastFinish.(TaskFunc)
+	assert.True(t, ok)
+}
+
+func TestTaskWrapper_NoObserverNoAllocs(t *testing.T) {
+	tw := &taskWrapper{t: TaskFunc(func(ctx context.Context) error { return nil })}
+	ctx := context.Background()
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = tw.Run(ctx)
+	})
+	assert.Equal(t, float64(0), allocs)
+}
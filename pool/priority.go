@@ -0,0 +1,228 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ecodeclub/ekit/bean/option"
+	"github.com/ecodeclub/ekit/queue"
+)
+
+// PriorityTask 带有优先级的任务
+// 在开启了优先级模式的 OnDemandBlockTaskPool 中，Submit 会使用 Priority 的返回值作为任务的优先级
+type PriorityTask interface {
+	Task
+	// Priority 返回任务的优先级，数值越大越先被执行
+	Priority() int
+}
+
+// WithPriorityQueue 开启优先级模式
+// 开启后等待中的任务不再按照提交顺序执行，而是优先执行优先级高的任务。
+// aging 是老化时间，任务每等待 aging 时间，其优先级相当于提升 1，
+// 从而保证低优先级的任务最终也能够被执行。aging 为 0 表示不启用老化。
+// 优先级模式要求 queueSize 大于 0
+func WithPriorityQueue(aging time.Duration) option.Option[OnDemandBlockTaskPool] {
+	return func(pool *OnDemandBlockTaskPool) {
+		pool.priorityAging = aging
+		// 工作协程先取走 priorityTicket 再从优先级队列中取任务，
+		// 所以优先级队列中的任务数可能短暂地多于 b.queue 的容量，这里使用无界队列，由 b.queue 限制容量
+		pool.priorityQueue = queue.NewConcurrentPriorityQueue[*priorityTask](0, comparePriorityTask)
+		pool.priorityWaiting = &priorityCounter{cnt: make(map[int]int)}
+	}
+}
+
+// priorityTask 是进入优先级队列的元素
+type priorityTask struct {
+	task     Task
+	priority int
+	// score 越小越先被执行
+	score int64
+	// seq 保证 score 相同时按照提交顺序执行
+	seq int64
+}
+
+func comparePriorityTask(src *priorityTask, dst *priorityTask) int {
+	switch {
+	case src.score < dst.score:
+		return -1
+	case src.score > dst.score:
+		return 1
+	case src.seq < dst.seq:
+		return -1
+	case src.seq > dst.seq:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// priorityTicket 是优先级模式下放入 b.queue 的占位任务
+// b.queue 中的每一个 priorityTicket 都对应着优先级队列中的一个任务，
+// 工作协程拿到 priorityTicket 之后，再从优先级队列中取出当前优先级最高的任务执行。
+// 这样 Shutdown/ShutdownNow 以及协程扩缩容的逻辑都不需要感知优先级队列
+type priorityTicket struct {
+	b *OnDemandBlockTaskPool
+}
+
+func (p priorityTicket) Run(ctx context.Context) error {
+	return p.b.dequeuePriorityTask().Run(ctx)
+}
+
+// priorityCounter 统计各个优先级等待中的任务数
+type priorityCounter struct {
+	mu  sync.Mutex
+	cnt map[int]int
+}
+
+func (c *priorityCounter) add(priority int, delta int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cnt[priority] += delta
+	if c.cnt[priority] <= 0 {
+		delete(c.cnt, priority)
+	}
+}
+
+func (c *priorityCounter) snapshot() map[int]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := make(map[int]int, len(c.cnt))
+	for k, v := range c.cnt {
+		res[k] = v
+	}
+	return res
+}
+
+// SubmitWithPriority 以指定的优先级提交一个任务，priority 越大越先被执行
+// 未开启优先级模式时 priority 会被忽略，等价于 Submit
+// 其余语义与 Submit 保持一致
+func (b *OnDemandBlockTaskPool) SubmitWithPriority(ctx context.Context, task Task, priority int) error {
+	if task == nil {
+		return fmt.Errorf("%w", errTaskIsInvalid)
+	}
+	if b.priorityQueue == nil {
+		return b.Submit(ctx, task)
+	}
+	return b.submitWithPriority(ctx, task, priority)
+}
+
+func (b *OnDemandBlockTaskPool) submitWithPriority(ctx context.Context, task Task, priority int) error {
+	pt := b.newPriorityTask(b.wrapTask(task), priority)
+	for {
+
+		if atomic.LoadInt32(&b.state) == stateClosing {
+			return fmt.Errorf("%w", errTaskPoolIsClosing)
+		}
+
+		if atomic.LoadInt32(&b.state) == stateStopped {
+			return fmt.Errorf("%w", errTaskPoolIsStopped)
+		}
+
+		for _, state := range [...]int32{stateCreated, stateRunning} {
+			ok, err := b.trySubmitPriority(ctx, pt, state)
+			if ok {
+				b.notifySubmitted(pt.task)
+				return nil
+			}
+			if err == errTaskQueueIsFull {
+				ok, err = b.reject(ctx, pt.task, state, func() { b.enqueuePriorityTask(pt) }, pt)
+			}
+			if ok || err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (b *OnDemandBlockTaskPool) trySubmitPriority(ctx context.Context, pt *priorityTask, state int32) (bool, error) {
+	if atomic.CompareAndSwapInt32(&b.state, state, stateLocked) {
+		defer atomic.CompareAndSwapInt32(&b.state, stateLocked, state)
+
+		if ctx.Err() != nil {
+			return false, fmt.Errorf("%w", ctx.Err())
+		}
+		// 临界区内只有当前协程能够往 b.queue 中放入元素，工作协程只会从中取走元素，
+		// 所以只要 b.queue 未满，下面的发送就一定不会阻塞，
+		// 也就保证了先入优先级队列再放入 priorityTicket 的顺序
+		if len(b.queue) >= cap(b.queue) {
+			if b.rejectPolicy != nil {
+				return false, errTaskQueueIsFull
+			}
+			return false, nil
+		}
+		b.enqueuePriorityTask(pt)
+		b.tryCreateGoroutine(state)
+		return true, nil
+	}
+	return false, nil
+}
+
+func (b *OnDemandBlockTaskPool) newPriorityTask(task Task, priority int) *priorityTask {
+	pt := &priorityTask{
+		task:     task,
+		priority: priority,
+		seq:      atomic.AddInt64(&b.prioritySeq, 1),
+	}
+	if b.priorityAging > 0 {
+		// 等待 aging 时间相当于优先级提升 1，
+		// 所以可以把优先级折算成提前了多少时间入队，按照折算后的入队时间排序即可
+		pt.score = time.Now().UnixNano() - int64(priority)*int64(b.priorityAging)
+	} else {
+		pt.score = -int64(priority)
+	}
+	return pt
+}
+
+// enqueuePriorityTask 将任务放入优先级队列，并往 b.queue 中放入对应的 priorityTicket
+// 调用者需要保证处于临界区内并且 b.queue 未满
+func (b *OnDemandBlockTaskPool) enqueuePriorityTask(pt *priorityTask) {
+	// 优先级队列是无界队列，入队不会失败
+	_ = b.priorityQueue.Enqueue(pt)
+	b.priorityWaiting.add(pt.priority, 1)
+	b.queue <- priorityTicket{b: b}
+}
+
+// dequeuePriorityTask 取出优先级最高的任务
+// 每个 priorityTicket 都对应优先级队列中的一个任务，所以此时队列不可能为空
+func (b *OnDemandBlockTaskPool) dequeuePriorityTask() Task {
+	pt, err := b.priorityQueue.Dequeue()
+	if err != nil {
+		panic(fmt.Sprintf("ekit: 优先级队列与任务队列不一致 %v", err))
+	}
+	b.priorityWaiting.add(pt.priority, -1)
+	return pt.task
+}
+
+// removeLowestPriorityTask 移除优先级最低的任务，但是只有它的优先级比 pt 低的时候才会移除
+// 调用者需要保证处于临界区内，并且已经取走了一个 priorityTicket：
+// 此时不会有新的任务入队，而工作协程只会取走优先级最高的任务，并且至少会留下一个任务，
+// 所以 PeekLast 和 DequeueLast 看到的是同一个任务
+func (b *OnDemandBlockTaskPool) removeLowestPriorityTask(pt *priorityTask) (*priorityTask, bool) {
+	lowest, err := b.priorityQueue.PeekLast()
+	if err != nil || comparePriorityTask(lowest, pt) < 0 {
+		return nil, false
+	}
+	lowest, err = b.priorityQueue.DequeueLast()
+	if err != nil {
+		return nil, false
+	}
+	b.priorityWaiting.add(lowest.priority, -1)
+	return lowest, true
+}
@@ -0,0 +1,251 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPriorityTask struct {
+	TaskFunc
+	priority int
+}
+
+func (t testPriorityTask) Priority() int {
+	return t.priority
+}
+
+func TestNewOnDemandBlockTaskPool_WithPriorityQueue(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewOnDemandBlockTaskPool(1, 0, WithPriorityQueue(0))
+	assert.ErrorIs(t, err, errInvalidArgument)
+
+	_, err = NewOnDemandBlockTaskPool(1, 1, WithPriorityQueue(-time.Second))
+	assert.ErrorIs(t, err, errInvalidArgument)
+
+	_, err = NewOnDemandBlockTaskPool(1, 1, WithPriorityQueue(time.Second))
+	assert.NoError(t, err)
+}
+
+func TestOnDemandBlockTaskPool_SubmitWithPriority(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		aging      time.Duration
+		priorities []int
+		// 每提交一个任务之后等待的时间
+		interval  time.Duration
+		wantOrder []int
+	}{
+		{
+			name:       "按照优先级从高到低执行",
+			priorities: []int{1, 3, 2, 0, 3},
+			wantOrder:  []int{1, 4, 2, 0, 3},
+		},
+		{
+			name:       "相同优先级按照提交顺序执行",
+			priorities: []int{0, 0, 0},
+			wantOrder:  []int{0, 1, 2},
+		},
+		{
+			name:       "等待足够久的低优先级任务先执行",
+			aging:      time.Millisecond,
+			priorities: []int{0, 5},
+			interval:   50 * time.Millisecond,
+			wantOrder:  []int{0, 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			p, err := NewOnDemandBlockTaskPool(1, len(tc.priorities), WithPriorityQueue(tc.aging))
+			require.NoError(t, err)
+
+			var mu sync.Mutex
+			var wg sync.WaitGroup
+			order := make([]int, 0, len(tc.priorities))
+			for i, priority := range tc.priorities {
+				i := i
+				wg.Add(1)
+				err = p.SubmitWithPriority(context.Background(), TaskFunc(func(ctx context.Context) error {
+					mu.Lock()
+					order = append(order, i)
+					mu.Unlock()
+					wg.Done()
+					return nil
+				}), priority)
+				require.NoError(t, err)
+				time.Sleep(tc.interval)
+			}
+			require.NoError(t, p.Start())
+			wg.Wait()
+			assert.Equal(t, tc.wantOrder, order)
+		})
+	}
+}
+
+func TestOnDemandBlockTaskPool_Submit_PriorityTask(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewOnDemandBlockTaskPool(1, 3, WithPriorityQueue(0))
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	order := make([]string, 0, 3)
+	newTask := func(name string) TaskFunc {
+		wg.Add(1)
+		return func(ctx context.Context) error {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			wg.Done()
+			return nil
+		}
+	}
+	require.NoError(t, p.Submit(context.Background(), newTask("normal")))
+	require.NoError(t, p.Submit(context.Background(), testPriorityTask{TaskFunc: newTask("low"), priority: -1}))
+	require.NoError(t, p.Submit(context.Background(), testPriorityTask{TaskFunc: newTask("high"), priority: 1}))
+
+	require.NoError(t, p.Start())
+	wg.Wait()
+	assert.Equal(t, []string{"high", "normal", "low"}, order)
+}
+
+func TestOnDemandBlockTaskPool_Priority_QueueFull(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewOnDemandBlockTaskPool(1, 1, WithPriorityQueue(0))
+	require.NoError(t, err)
+	require.NoError(t, p.SubmitWithPriority(context.Background(), TaskFunc(func(ctx context.Context) error { return nil }), 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	err = p.SubmitWithPriority(ctx, TaskFunc(func(ctx context.Context) error { return nil }), 1)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestOnDemandBlockTaskPool_Priority_States(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewOnDemandBlockTaskPool(1, 5, WithPriorityQueue(time.Second))
+	require.NoError(t, err)
+	for _, priority := range []int{1, 1, 2} {
+		err = p.SubmitWithPriority(context.Background(), TaskFunc(func(ctx context.Context) error { return nil }), priority)
+		require.NoError(t, err)
+	}
+
+	ch, err := p.States(context.Background(), time.Millisecond)
+	require.NoError(t, err)
+	state := <-ch
+	assert.Equal(t, 3, state.WaitingTasksCnt)
+	assert.Equal(t, map[int]int{1: 2, 2: 1}, state.WaitingTasksCntByPriority)
+}
+
+func TestOnDemandBlockTaskPool_Priority_ShutdownNow(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewOnDemandBlockTaskPool(1, 3, WithPriorityQueue(0))
+	require.NoError(t, err)
+	require.NoError(t, p.Start())
+
+	wait := make(chan struct{})
+	running := make(chan struct{})
+	err = p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error {
+		close(running)
+		<-wait
+		return nil
+	}))
+	require.NoError(t, err)
+	<-running
+
+	for _, priority := range []int{1, 2, 3} {
+		err = p.SubmitWithPriority(context.Background(), TaskFunc(func(ctx context.Context) error { return nil }), priority)
+		require.NoError(t, err)
+	}
+
+	tasks, err := p.ShutdownNow()
+	require.NoError(t, err)
+	close(wait)
+	assert.Equal(t, 3, len(tasks))
+	for _, task := range tasks {
+		_, ok := task.(*taskWrapper)
+		assert.True(t, ok)
+	}
+}
+
+func TestOnDemandBlockTaskPool_Priority_Shutdown(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewOnDemandBlockTaskPool(2, 10, WithPriorityQueue(time.Millisecond))
+	require.NoError(t, err)
+	require.NoError(t, p.Start())
+
+	var mu sync.Mutex
+	cnt := 0
+	for i := 0; i < 10; i++ {
+		err = p.SubmitWithPriority(context.Background(), TaskFunc(func(ctx context.Context) error {
+			mu.Lock()
+			cnt++
+			mu.Unlock()
+			return nil
+		}), i%3)
+		require.NoError(t, err)
+	}
+	done, err := p.Shutdown()
+	require.NoError(t, err)
+	<-done
+	assert.Equal(t, 10, cnt)
+}
+
+func TestOnDemandBlockTaskPool_RemoveLowestPriorityTask(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewOnDemandBlockTaskPool(1, 10, WithPriorityQueue(0))
+	require.NoError(t, err)
+	_, ok := p.removeLowestPriorityTask(&priorityTask{})
+	assert.False(t, ok)
+	for i, priority := range []int{3, 1, 5, 2, 4, 1} {
+		require.NoError(t, p.priorityQueue.Enqueue(&priorityTask{priority: priority, score: -int64(priority), seq: int64(i)}))
+		p.priorityWaiting.add(priority, 1)
+	}
+
+	// 优先级相同时后提交的任务更低
+	lowest, ok := p.removeLowestPriorityTask(&priorityTask{priority: 3, score: -3, seq: 10})
+	require.True(t, ok)
+	assert.Equal(t, int64(5), lowest.seq)
+	assert.Equal(t, map[int]int{1: 1, 2: 1, 3: 1, 4: 1, 5: 1}, p.priorityWaiting.snapshot())
+	// 新任务的优先级最低时不会移除
+	_, ok = p.removeLowestPriorityTask(&priorityTask{priority: 1, score: -1, seq: 10})
+	assert.False(t, ok)
+
+	var priorities []int
+	for p.priorityQueue.Len() > 0 {
+		pt, err := p.priorityQueue.Dequeue()
+		require.NoError(t, err)
+		priorities = append(priorities, pt.priority)
+	}
+	assert.Equal(t, []int{5, 4, 3, 2, 1}, priorities)
+}
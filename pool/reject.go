@@ -0,0 +1,197 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ecodeclub/ekit/bean/option"
+)
+
+var (
+	// ErrTaskRejected 任务队列已满，任务被 AbortPolicy 拒绝
+	ErrTaskRejected = errors.New("ekit: 任务队列已满，任务被拒绝")
+	// ErrTaskDiscarded 任务没有执行就被丢弃了，例如被 DiscardPolicy 丢弃，或者因为 ShutdownNow 没有执行
+	ErrTaskDiscarded = errors.New("ekit: 任务未执行就被丢弃")
+
+	errTaskQueueIsFull = errors.New("ekit: 任务队列已满")
+
+	_ RejectPolicy = AbortPolicy{}
+	_ RejectPolicy = CallerRunsPolicy{}
+	_ RejectPolicy = DiscardPolicy{}
+	_ RejectPolicy = DiscardOldestPolicy{}
+	_ RejectPolicy = &DiscardOldestPolicy{}
+)
+
+// RejectPolicy 任务队列已满时的拒绝策略
+type RejectPolicy interface {
+	// Reject 处理被拒绝的任务，返回值会作为 Submit 的返回值
+	// task 是已经被 TaskPool 装饰过的任务，执行时 panic 会被转化为 error
+	Reject(ctx context.Context, task Task) error
+}
+
+// RejectPolicyFunc 函数式的 RejectPolicy
+type RejectPolicyFunc func(ctx context.Context, task Task) error
+
+func (r RejectPolicyFunc) Reject(ctx context.Context, task Task) error {
+	return r(ctx, task)
+}
+
+// AbortPolicy 直接返回 ErrTaskRejected
+type AbortPolicy struct{}
+
+func (AbortPolicy) Reject(ctx context.Context, task Task) error {
+	return fmt.Errorf("%w", ErrTaskRejected)
+}
+
+// CallerRunsPolicy 在调用 Submit 的 goroutine 中直接执行任务
+// 任务本身返回的 error 会被忽略，与工作协程中执行任务的行为保持一致
+type CallerRunsPolicy struct{}
+
+func (CallerRunsPolicy) Reject(ctx context.Context, task Task) error {
+	_ = task.Run(ctx)
+	return nil
+}
+
+// DiscardPolicy 直接丢弃新提交的任务，Submit 返回 nil
+type DiscardPolicy struct{}
+
+func (DiscardPolicy) Reject(ctx context.Context, task Task) error {
+	notifyDiscarded(task)
+	return nil
+}
+
+// DiscardOldestPolicy 丢弃队首的任务，然后将新任务放入队列
+// 开启优先级模式时，丢弃的是优先级最低的任务，如果新任务的优先级不高于所有等待中的任务，那么丢弃新任务
+// 被丢弃的任务（用户提交的原始任务）会传给 OnDiscard，OnDiscard 可以为 nil
+// 队列容量为 0 时没有可以丢弃的旧任务，此时丢弃新任务
+// 使用 DiscardOldestPolicy 或者 *DiscardOldestPolicy 都可以
+type DiscardOldestPolicy struct {
+	OnDiscard func(task Task)
+}
+
+func (d DiscardOldestPolicy) Reject(ctx context.Context, task Task) error {
+	d.discard(task)
+	return nil
+}
+
+func (d DiscardOldestPolicy) discard(task Task) {
+	notifyDiscarded(task)
+	if d.OnDiscard != nil {
+		d.OnDiscard(unwrapTask(task))
+	}
+}
+
+// discardAware 是需要感知自己被丢弃的任务，例如 TaskGroup 提交的任务
+// 被丢弃之后再执行该任务不会有任何效果，Run 直接返回 ErrTaskDiscarded
+type discardAware interface {
+	discarded()
+}
+
+// notifyDiscarded 通知没有执行就被丢弃的任务
+func notifyDiscarded(tasks ...Task) {
+	for _, task := range tasks {
+		if d, ok := unwrapTask(task).(discardAware); ok {
+			d.discarded()
+		}
+	}
+}
+
+// discardOldestPolicy 判断 policy 是否为 DiscardOldestPolicy 或者 *DiscardOldestPolicy
+func discardOldestPolicy(policy RejectPolicy) (DiscardOldestPolicy, bool) {
+	switch p := policy.(type) {
+	case DiscardOldestPolicy:
+		return p, true
+	case *DiscardOldestPolicy:
+		if p == nil {
+			return DiscardOldestPolicy{}, true
+		}
+		return *p, true
+	default:
+		return DiscardOldestPolicy{}, false
+	}
+}
+
+// WithRejectPolicy 设置任务队列已满时的拒绝策略
+// 默认情况下没有拒绝策略，任务队列已满时 Submit 会阻塞直到 ctx 过期
+func WithRejectPolicy(policy RejectPolicy) option.Option[OnDemandBlockTaskPool] {
+	return func(pool *OnDemandBlockTaskPool) {
+		pool.rejectPolicy = policy
+	}
+}
+
+// reject 使用拒绝策略处理任务
+// enqueue 用于 DiscardOldestPolicy 丢弃旧任务之后将新任务放入队列
+// pt 是优先级模式下新任务对应的 priorityTask，非优先级模式下为 nil
+// 返回 false 表示因为 TaskPool 状态变化没有处理成功，调用者需要重试
+func (b *OnDemandBlockTaskPool) reject(ctx context.Context, task Task, state int32, enqueue func(), pt *priorityTask) (bool, error) {
+	if p, ok := discardOldestPolicy(b.rejectPolicy); ok && cap(b.queue) > 0 {
+		evicted, enqueued, ok := b.tryDiscardOldest(state, enqueue, pt)
+		if !ok {
+			return false, nil
+		}
+		if enqueued {
+			b.notifySubmitted(task)
+		}
+		if evicted != nil {
+			b.notifyRejected(evicted)
+			p.discard(evicted)
+		}
+		return true, nil
+	}
+	b.notifyRejected(task)
+	return true, b.rejectPolicy.Reject(ctx, task)
+}
+
+// tryDiscardOldest 在临界区内丢弃队首的任务，并将新任务放入队列
+// 临界区内只有当前协程能够往 b.queue 中放入元素，所以丢弃之后 enqueue 一定不会阻塞
+// 如果此时队列已经不满了，那么不会丢弃任何任务
+// 优先级模式下丢弃优先级最低的任务，如果新任务的优先级最低，那么丢弃新任务，此时 enqueued 为 false
+func (b *OnDemandBlockTaskPool) tryDiscardOldest(state int32, enqueue func(), pt *priorityTask) (evicted Task, enqueued bool, ok bool) {
+	if !atomic.CompareAndSwapInt32(&b.state, state, stateLocked) {
+		return nil, false, false
+	}
+	defer atomic.CompareAndSwapInt32(&b.state, stateLocked, state)
+
+	if len(b.queue) >= cap(b.queue) {
+		select {
+		case evicted = <-b.queue:
+			if _, isTicket := evicted.(priorityTicket); isTicket {
+				// 先取走 priorityTicket，保证优先级队列中至少还有一个任务没有对应的工作协程
+				lowest, removed := b.removeLowestPriorityTask(pt)
+				if !removed {
+					// 新任务的优先级最低，放回 priorityTicket，此时 b.queue 一定未满
+					b.queue <- evicted
+					return pt.task, false, true
+				}
+				evicted = lowest.task
+			}
+		default:
+		}
+	}
+	enqueue()
+	b.tryCreateGoroutine(state)
+	return evicted, true, true
+}
+
+func (b *OnDemandBlockTaskPool) notifyRejected(task Task) {
+	atomic.AddInt64(&b.rejectedCnt, 1)
+	if b.observer != nil {
+		b.observer.OnTaskRejected(unwrapTask(task))
+	}
+}
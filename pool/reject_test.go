@@ -0,0 +1,200 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnDemandBlockTaskPool_RejectPolicy(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		policy  RejectPolicy
+		wantErr error
+		// 被拒绝的任务是否被执行
+		wantRun bool
+	}{
+		{
+			name:    "AbortPolicy",
+			policy:  AbortPolicy{},
+			wantErr: ErrTaskRejected,
+		},
+		{
+			name:    "CallerRunsPolicy",
+			policy:  CallerRunsPolicy{},
+			wantRun: true,
+		},
+		{
+			name:   "DiscardPolicy",
+			policy: DiscardPolicy{},
+		},
+		{
+			name: "RejectPolicyFunc",
+			policy: RejectPolicyFunc(func(ctx context.Context, task Task) error {
+				return errors.New("mock error")
+			}),
+			wantErr: errors.New("mock error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			// 未启动的 TaskPool 中队列满了之后不会有任务被取走
+			p, err := NewOnDemandBlockTaskPool(1, 1, WithRejectPolicy(tc.policy))
+			require.NoError(t, err)
+			require.NoError(t, p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error { return nil })))
+
+			run := false
+			err = p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error {
+				run = true
+				panic("caller runs panic")
+			}))
+			if tc.wantErr == ErrTaskRejected {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else {
+				assert.Equal(t, tc.wantErr, err)
+			}
+			assert.Equal(t, tc.wantRun, run)
+			assert.Equal(t, int64(1), p.getState(time.Now().UnixNano()).RejectedTasksCnt)
+			assert.Equal(t, 1, len(p.queue))
+		})
+	}
+}
+
+func TestOnDemandBlockTaskPool_DiscardOldestPolicy(t *testing.T) {
+	t.Parallel()
+
+	t.Run("丢弃队首任务", func(t *testing.T) {
+		t.Parallel()
+		var discarded []Task
+		p, err := NewOnDemandBlockTaskPool(1, 2, WithRejectPolicy(DiscardOldestPolicy{
+			OnDiscard: func(task Task) {
+				discarded = append(discarded, task)
+			},
+		}))
+		require.NoError(t, err)
+
+		order := make([]int, 0, 2)
+		for i := 0; i < 4; i++ {
+			i := i
+			err = p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error {
+				order = append(order, i)
+				return nil
+			}))
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 2, len(discarded))
+		assert.Equal(t, int64(2), p.getState(time.Now().UnixNano()).RejectedTasksCnt)
+
+		for _, task := range discarded {
+			require.NoError(t, task.Run(context.Background()))
+		}
+		assert.Equal(t, []int{0, 1}, order)
+
+		require.NoError(t, p.Start())
+		done, err := p.Shutdown()
+		require.NoError(t, err)
+		<-done
+		assert.Equal(t, []int{0, 1, 2, 3}, order)
+	})
+
+	t.Run("优先级模式下丢弃优先级最低的任务", func(t *testing.T) {
+		t.Parallel()
+		var discarded []int
+		p, err := NewOnDemandBlockTaskPool(1, 2, WithPriorityQueue(0), WithRejectPolicy(DiscardOldestPolicy{
+			OnDiscard: func(task Task) {
+				_ = task.Run(context.Background())
+			},
+		}))
+		require.NoError(t, err)
+
+		submit := func(priority int) {
+			err := p.SubmitWithPriority(context.Background(), TaskFunc(func(ctx context.Context) error {
+				discarded = append(discarded, priority)
+				return nil
+			}), priority)
+			require.NoError(t, err)
+		}
+		for _, priority := range []int{1, 3, 2} {
+			submit(priority)
+		}
+		assert.Equal(t, []int{1}, discarded)
+		assert.Equal(t, map[int]int{2: 1, 3: 1}, p.getState(time.Now().UnixNano()).WaitingTasksCntByPriority)
+
+		// 新任务的优先级最低时丢弃新任务
+		submit(2)
+		assert.Equal(t, []int{1, 2}, discarded)
+		assert.Equal(t, map[int]int{2: 1, 3: 1}, p.getState(time.Now().UnixNano()).WaitingTasksCntByPriority)
+		assert.Equal(t, int64(2), p.getState(time.Now().UnixNano()).RejectedTasksCnt)
+		assert.Equal(t, 2, len(p.queue))
+
+		// 剩余的任务依然按照优先级执行
+		discarded = nil
+		require.NoError(t, p.Start())
+		done, err := p.Shutdown()
+		require.NoError(t, err)
+		<-done
+		assert.Equal(t, []int{3, 2}, discarded)
+	})
+
+	t.Run("使用指针并且OnDiscard收到原始任务", func(t *testing.T) {
+		t.Parallel()
+		var discarded []Task
+		p, err := NewOnDemandBlockTaskPool(1, 1, WithRejectPolicy(&DiscardOldestPolicy{
+			OnDiscard: func(task Task) {
+				discarded = append(discarded, task)
+			},
+		}))
+		require.NoError(t, err)
+
+		first := &discardTestTask{id: 1}
+		require.NoError(t, p.Submit(context.Background(), first))
+		require.NoError(t, p.Submit(context.Background(), &discardTestTask{id: 2}))
+		assert.Equal(t, []Task{first}, discarded)
+		assert.Equal(t, 1, len(p.queue))
+	})
+
+	t.Run("队列容量为0时丢弃新任务", func(t *testing.T) {
+		t.Parallel()
+		var discarded []Task
+		p, err := NewOnDemandBlockTaskPool(1, 0, WithRejectPolicy(DiscardOldestPolicy{
+			OnDiscard: func(task Task) {
+				discarded = append(discarded, task)
+			},
+		}))
+		require.NoError(t, err)
+		err = p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error { return nil }))
+		require.NoError(t, err)
+		assert.Equal(t, 1, len(discarded))
+	})
+}
+
+type discardTestTask struct {
+	id int
+}
+
+func (d *discardTestTask) Run(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,295 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ecodeclub/ekit/bean/option"
+	"github.com/ecodeclub/ekit/queue"
+)
+
+var errScheduledTaskPoolIsShutdown = errors.New("ekit: ScheduledTaskPool已关闭")
+
+const (
+	scheduleOnce int = iota
+	scheduleFixedDelay
+	scheduleFixedRate
+)
+
+// ScheduledTaskPool 定时任务池
+// 它只负责在指定的时间将任务提交到 TaskPool 中，任务的执行依旧由 TaskPool 负责，
+// 所以任务实际执行的时间还取决于 TaskPool 的繁忙程度
+type ScheduledTaskPool struct {
+	pool  TaskPool
+	queue *queue.DelayQueue[*scheduledEntry]
+
+	// 尚未结束的定时任务
+	handles  map[*ScheduleHandle]struct{}
+	mutex    sync.Mutex
+	shutdown bool
+	// Shutdown 的时候是否丢弃周期任务
+	dropPeriodicOnShutdown bool
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// NewScheduledTaskPool 创建一个 ScheduledTaskPool，到期的任务会被提交到 p 中执行
+// 创建之后就会开始调度，p 是否已经 Start 不影响调度，只影响任务什么时候被执行
+func NewScheduledTaskPool(p TaskPool, opts ...option.Option[ScheduledTaskPool]) *ScheduledTaskPool {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &ScheduledTaskPool{
+		pool:    p,
+		queue:   queue.NewDelayQueue[*scheduledEntry](0),
+		handles: make(map[*ScheduleHandle]struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	option.Apply(s, opts...)
+	go s.dispatch()
+	return s
+}
+
+// WithDropPeriodicOnShutdown 设置 Shutdown 时是否丢弃周期任务
+// 默认情况下 Shutdown 之后周期任务会继续执行，直到被 Cancel
+func WithDropPeriodicOnShutdown(drop bool) option.Option[ScheduledTaskPool] {
+	return func(s *ScheduledTaskPool) {
+		s.dropPeriodicOnShutdown = drop
+	}
+}
+
+// ScheduleAt 在 at 时刻执行一次 task
+// 如果 at 已经过去，那么会立刻执行
+func (s *ScheduledTaskPool) ScheduleAt(task Task, at time.Time) (*ScheduleHandle, error) {
+	return s.schedule(task, scheduleOnce, at, 0)
+}
+
+// ScheduleWithFixedDelay 在 initialDelay 之后第一次执行 task，
+// 之后每次在上一次执行结束之后间隔 delay 再执行
+func (s *ScheduledTaskPool) ScheduleWithFixedDelay(task Task, initialDelay, delay time.Duration) (*ScheduleHandle, error) {
+	if delay <= 0 {
+		return nil, fmt.Errorf("%w：delay应该大于0", errInvalidArgument)
+	}
+	return s.schedule(task, scheduleFixedDelay, time.Now().Add(initialDelay), delay)
+}
+
+// ScheduleAtFixedRate 在 initialDelay 之后第一次执行 task，之后每隔 period 执行一次
+// 同一个任务不会并发执行：如果某次执行耗时超过了 period，那么错过的执行会被跳过，
+// 下一次执行发生在下一个 period 的整数倍时刻，这与 time.Ticker 的行为一致
+func (s *ScheduledTaskPool) ScheduleAtFixedRate(task Task, initialDelay, period time.Duration) (*ScheduleHandle, error) {
+	if period <= 0 {
+		return nil, fmt.Errorf("%w：period应该大于0", errInvalidArgument)
+	}
+	return s.schedule(task, scheduleFixedRate, time.Now().Add(initialDelay), period)
+}
+
+func (s *ScheduledTaskPool) schedule(task Task, kind int, at time.Time, interval time.Duration) (*ScheduleHandle, error) {
+	if task == nil {
+		return nil, fmt.Errorf("%w", errTaskIsInvalid)
+	}
+	h := &ScheduleHandle{
+		s:        s,
+		task:     task,
+		kind:     kind,
+		interval: interval,
+	}
+	s.mutex.Lock()
+	if s.shutdown {
+		s.mutex.Unlock()
+		return nil, fmt.Errorf("%w", errScheduledTaskPoolIsShutdown)
+	}
+	s.handles[h] = struct{}{}
+	s.mutex.Unlock()
+	s.enqueue(h, at)
+	return h, nil
+}
+
+func (s *ScheduledTaskPool) enqueue(h *ScheduleHandle, at time.Time) {
+	atomic.StoreInt64(&h.next, at.UnixNano())
+	// 无界队列，入队不会阻塞
+	_ = s.queue.Enqueue(context.Background(), &scheduledEntry{h: h, at: at})
+}
+
+func (s *ScheduledTaskPool) dispatch() {
+	for {
+		entry, err := s.queue.Dequeue(s.ctx)
+		if err != nil {
+			return
+		}
+		if entry.h.Cancelled() {
+			continue
+		}
+		// TaskPool 满的时候 Submit 会阻塞，
+		// 所以在单独的 goroutine 中提交，避免耽误其它定时任务的调度
+		go s.submit(entry)
+	}
+}
+
+// submit 将到期的任务提交到 TaskPool 中
+// 周期任务在本次执行结束之后才会计算下一次执行的时间，所以同一个定时任务最多只有一个提交中的执行
+func (s *ScheduledTaskPool) submit(entry *scheduledEntry) {
+	h := entry.h
+	err := s.pool.Submit(s.ctx, TaskFunc(func(ctx context.Context) error {
+		if h.kind == scheduleOnce {
+			defer s.remove(h)
+		} else {
+			defer s.reschedule(h, entry.at)
+		}
+		if h.Cancelled() {
+			return nil
+		}
+		return h.task.Run(ctx)
+	}))
+	if err != nil {
+		// 提交失败的周期任务等待下一个周期，一次性任务直接结束
+		if h.kind == scheduleOnce {
+			s.remove(h)
+		} else {
+			s.reschedule(h, entry.at)
+		}
+	}
+}
+
+// reschedule 计算周期任务下一次执行的时间并重新放入队列
+func (s *ScheduledTaskPool) reschedule(h *ScheduleHandle, prev time.Time) {
+	if h.Cancelled() {
+		return
+	}
+	s.mutex.Lock()
+	drop := s.shutdown && s.dropPeriodicOnShutdown
+	s.mutex.Unlock()
+	if drop {
+		s.remove(h)
+		return
+	}
+	now := time.Now()
+	var next time.Time
+	if h.kind == scheduleFixedDelay {
+		next = now.Add(h.interval)
+	} else {
+		next = prev.Add(h.interval)
+		if !next.After(now) {
+			// 跳过错过的周期
+			next = prev.Add((now.Sub(prev)/h.interval + 1) * h.interval)
+		}
+	}
+	s.enqueue(h, next)
+}
+
+// remove 结束一个定时任务
+// 如果 ScheduledTaskPool 已经关闭并且所有的定时任务都已结束，那么停止调度
+func (s *ScheduledTaskPool) remove(h *ScheduleHandle) {
+	atomic.StoreInt32(&h.cancelled, 1)
+	s.mutex.Lock()
+	delete(s.handles, h)
+	finished := s.shutdown && len(s.handles) == 0
+	s.mutex.Unlock()
+	if finished {
+		s.finish()
+	}
+}
+
+func (s *ScheduledTaskPool) finish() {
+	s.doneOnce.Do(func() {
+		s.cancel()
+		close(s.done)
+	})
+}
+
+// Shutdown 关闭 ScheduledTaskPool，之后不能再添加新的定时任务
+// 已经添加的一次性任务依旧会在到期后提交执行；
+// 周期任务在设置了 WithDropPeriodicOnShutdown(true) 时会被丢弃，否则会继续执行直到被 Cancel
+// 当所有的定时任务都已结束时，返回的 chan 会被关闭
+// Shutdown 不会关闭底层的 TaskPool
+func (s *ScheduledTaskPool) Shutdown() (<-chan struct{}, error) {
+	s.mutex.Lock()
+	if s.shutdown {
+		s.mutex.Unlock()
+		return nil, fmt.Errorf("%w", errScheduledTaskPoolIsShutdown)
+	}
+	s.shutdown = true
+	var dropped []*ScheduleHandle
+	if s.dropPeriodicOnShutdown {
+		for h := range s.handles {
+			if h.kind != scheduleOnce {
+				dropped = append(dropped, h)
+			}
+		}
+	}
+	finished := len(s.handles) == 0
+	s.mutex.Unlock()
+
+	for _, h := range dropped {
+		h.Cancel()
+	}
+	if finished {
+		s.finish()
+	}
+	return s.done, nil
+}
+
+// ScheduleHandle 代表一个已经添加的定时任务
+type ScheduleHandle struct {
+	s        *ScheduledTaskPool
+	task     Task
+	kind     int
+	interval time.Duration
+
+	// 下一次执行的时间，UnixNano
+	next      int64
+	cancelled int32
+}
+
+// Cancel 取消定时任务
+// 已经提交到 TaskPool 但尚未开始的执行会被跳过，正在进行的执行不受影响
+// 如果定时任务已经结束，那么返回 false
+func (h *ScheduleHandle) Cancel() bool {
+	if !atomic.CompareAndSwapInt32(&h.cancelled, 0, 1) {
+		return false
+	}
+	h.s.remove(h)
+	return true
+}
+
+// Cancelled 定时任务是否已经结束
+// 被取消，或者一次性任务已经执行完毕，都认为定时任务已经结束
+func (h *ScheduleHandle) Cancelled() bool {
+	return atomic.LoadInt32(&h.cancelled) == 1
+}
+
+// NextFireTime 返回下一次执行的时间
+// 对于正在执行中的周期任务，返回的是本次执行的计划时间
+func (h *ScheduleHandle) NextFireTime() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&h.next))
+}
+
+// scheduledEntry 是延时队列中的元素
+type scheduledEntry struct {
+	h  *ScheduleHandle
+	at time.Time
+}
+
+func (e *scheduledEntry) Delay() time.Duration {
+	return time.Until(e.at)
+}
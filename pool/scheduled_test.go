@@ -0,0 +1,240 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduledTaskPool_ScheduleAt(t *testing.T) {
+	t.Parallel()
+
+	s := NewScheduledTaskPool(testNewRunningStateTaskPool(t, 1, 1))
+	fired := make(chan time.Time, 1)
+	at := time.Now().Add(20 * time.Millisecond)
+	h, err := s.ScheduleAt(TaskFunc(func(ctx context.Context) error {
+		fired <- time.Now()
+		return nil
+	}), at)
+	require.NoError(t, err)
+	assert.Equal(t, at.UnixNano(), h.NextFireTime().UnixNano())
+	assert.False(t, h.Cancelled())
+
+	firedAt := <-fired
+	assert.False(t, firedAt.Before(at))
+	assert.Eventually(t, h.Cancelled, time.Second, time.Millisecond)
+	assert.False(t, h.Cancel())
+
+	_, err = s.ScheduleAt(nil, at)
+	assert.ErrorIs(t, err, errTaskIsInvalid)
+}
+
+func TestScheduledTaskPool_Cancel(t *testing.T) {
+	t.Parallel()
+
+	s := NewScheduledTaskPool(testNewRunningStateTaskPool(t, 1, 1))
+	var cnt int32
+	h, err := s.ScheduleAt(TaskFunc(func(ctx context.Context) error {
+		atomic.AddInt32(&cnt, 1)
+		return nil
+	}), time.Now().Add(20*time.Millisecond))
+	require.NoError(t, err)
+	assert.True(t, h.Cancel())
+	assert.False(t, h.Cancel())
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&cnt))
+}
+
+func TestScheduledTaskPool_Periodic(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		schedule func(s *ScheduledTaskPool, task Task) (*ScheduleHandle, error)
+	}{
+		{
+			name: "fixed delay",
+			schedule: func(s *ScheduledTaskPool, task Task) (*ScheduleHandle, error) {
+				return s.ScheduleWithFixedDelay(task, 0, 5*time.Millisecond)
+			},
+		},
+		{
+			name: "fixed rate",
+			schedule: func(s *ScheduledTaskPool, task Task) (*ScheduleHandle, error) {
+				return s.ScheduleAtFixedRate(task, 0, 5*time.Millisecond)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			s := NewScheduledTaskPool(testNewRunningStateTaskPool(t, 1, 1))
+			var cnt int32
+			h, err := tc.schedule(s, TaskFunc(func(ctx context.Context) error {
+				atomic.AddInt32(&cnt, 1)
+				return nil
+			}))
+			require.NoError(t, err)
+			assert.Eventually(t, func() bool {
+				return atomic.LoadInt32(&cnt) >= 3
+			}, time.Second, time.Millisecond)
+			assert.True(t, h.NextFireTime().After(time.Now().Add(-10*time.Millisecond)))
+
+			assert.True(t, h.Cancel())
+			// 等待可能正在执行的任务结束
+			time.Sleep(10 * time.Millisecond)
+			n := atomic.LoadInt32(&cnt)
+			time.Sleep(20 * time.Millisecond)
+			assert.Equal(t, n, atomic.LoadInt32(&cnt))
+		})
+	}
+}
+
+func TestScheduledTaskPool_SubmitBlocked(t *testing.T) {
+	t.Parallel()
+
+	// 一个定时任务提交时阻塞，不会影响其它定时任务的调度
+	release := make(chan struct{})
+	p := &blockOnceTaskPool{
+		TaskPool: testNewRunningStateTaskPool(t, 1, 1),
+		release:  release,
+		blocking: make(chan struct{}),
+	}
+	s := NewScheduledTaskPool(p)
+	var blocked int32
+	_, err := s.ScheduleAt(TaskFunc(func(ctx context.Context) error {
+		atomic.AddInt32(&blocked, 1)
+		return nil
+	}), time.Now())
+	require.NoError(t, err)
+	<-p.blocking
+
+	var cnt int32
+	h, err := s.ScheduleAtFixedRate(TaskFunc(func(ctx context.Context) error {
+		atomic.AddInt32(&cnt, 1)
+		return nil
+	}), 0, 5*time.Millisecond)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&cnt) >= 3
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&blocked))
+	h.Cancel()
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&blocked) == 1
+	}, time.Second, time.Millisecond)
+}
+
+// blockOnceTaskPool 第一次 Submit 会阻塞直到 release 被关闭
+type blockOnceTaskPool struct {
+	TaskPool
+	release  chan struct{}
+	blocking chan struct{}
+	once     sync.Once
+}
+
+func (b *blockOnceTaskPool) Submit(ctx context.Context, task Task) error {
+	first := false
+	b.once.Do(func() {
+		first = true
+	})
+	if first {
+		close(b.blocking)
+		select {
+		case <-b.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return b.TaskPool.Submit(ctx, task)
+}
+
+func TestScheduledTaskPool_InvalidInterval(t *testing.T) {
+	t.Parallel()
+
+	s := NewScheduledTaskPool(testNewRunningStateTaskPool(t, 1, 1))
+	task := TaskFunc(func(ctx context.Context) error { return nil })
+	_, err := s.ScheduleWithFixedDelay(task, 0, 0)
+	assert.ErrorIs(t, err, errInvalidArgument)
+	_, err = s.ScheduleAtFixedRate(task, 0, -time.Second)
+	assert.ErrorIs(t, err, errInvalidArgument)
+}
+
+func TestScheduledTaskPool_Shutdown(t *testing.T) {
+	t.Parallel()
+
+	t.Run("丢弃周期任务", func(t *testing.T) {
+		t.Parallel()
+		s := NewScheduledTaskPool(testNewRunningStateTaskPool(t, 1, 1), WithDropPeriodicOnShutdown(true))
+		task := TaskFunc(func(ctx context.Context) error { return nil })
+		periodic, err := s.ScheduleAtFixedRate(task, time.Hour, time.Hour)
+		require.NoError(t, err)
+
+		var fired int32
+		once, err := s.ScheduleAt(TaskFunc(func(ctx context.Context) error {
+			atomic.AddInt32(&fired, 1)
+			return nil
+		}), time.Now().Add(10*time.Millisecond))
+		require.NoError(t, err)
+
+		done, err := s.Shutdown()
+		require.NoError(t, err)
+		assert.True(t, periodic.Cancelled())
+		<-done
+		assert.Equal(t, int32(1), atomic.LoadInt32(&fired))
+		assert.True(t, once.Cancelled())
+
+		_, err = s.ScheduleAt(task, time.Now())
+		assert.ErrorIs(t, err, errScheduledTaskPoolIsShutdown)
+		_, err = s.Shutdown()
+		assert.ErrorIs(t, err, errScheduledTaskPoolIsShutdown)
+	})
+
+	t.Run("保留周期任务", func(t *testing.T) {
+		t.Parallel()
+		s := NewScheduledTaskPool(testNewRunningStateTaskPool(t, 1, 1))
+		var cnt int32
+		periodic, err := s.ScheduleWithFixedDelay(TaskFunc(func(ctx context.Context) error {
+			atomic.AddInt32(&cnt, 1)
+			return nil
+		}), 0, time.Millisecond)
+		require.NoError(t, err)
+
+		done, err := s.Shutdown()
+		require.NoError(t, err)
+		assert.Eventually(t, func() bool {
+			return atomic.LoadInt32(&cnt) >= 3
+		}, time.Second, time.Millisecond)
+
+		select {
+		case <-done:
+			t.Fatal("周期任务未结束时不应该关闭")
+		default:
+		}
+		periodic.Cancel()
+		<-done
+	})
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// ShutdownSummary 是 ShutdownWithContext 的统计结果
+type ShutdownSummary struct {
+	// Completed 关闭期间执行成功的任务数
+	Completed int64
+	// Failed 关闭期间执行失败的任务数，包括返回 error 和 panic 的任务
+	Failed int64
+	// Dropped 直到 ctx 过期都没有开始执行而被丢弃的任务数
+	Dropped int
+	// Interrupted ctx 过期时仍在执行、通过 ctx 被通知中断的任务数
+	Interrupted int
+}
+
+// ShutdownWithContext 优雅关闭任务池
+// 与 Shutdown 一样，任务池将会拒绝提交新的任务，并继续执行队列中的任务。
+// 如果在 ctx 过期之前所有任务都执行完毕，那么返回 nil 任务和 nil error。
+// 否则与 ShutdownNow 一样，通过传给任务的 ctx 中断正在执行的任务，并返回所有未完成的任务以及 ctx.Err()：
+// 返回的任务中前 Dropped 个是尚未开始执行的任务，它们与 ShutdownNow 一样会被通知丢弃；
+// 其余 Interrupted 个是被中断的任务，它们在返回之后可能仍在执行，不会计入 Completed 和 Failed
+// 注意：恰好在 ctx 过期时被工作协程取走的任务可能不在返回的任务中
+func (b *OnDemandBlockTaskPool) ShutdownWithContext(ctx context.Context) ([]Task, ShutdownSummary, error) {
+	completed, failed := atomic.LoadInt64(&b.completedCnt), atomic.LoadInt64(&b.failedCnt)
+	done, err := b.Shutdown()
+	if err != nil {
+		return nil, ShutdownSummary{}, err
+	}
+
+	select {
+	case <-done:
+		return nil, b.shutdownSummary(completed, failed), nil
+	case <-ctx.Done():
+	}
+
+	// 最后一个工作协程也可能恰好在此时完成状态迁移
+	if !atomic.CompareAndSwapInt32(&b.state, stateClosing, stateStopped) {
+		<-done
+		return nil, b.shutdownSummary(completed, failed), nil
+	}
+	interrupted := b.runningTaskList()
+	b.interruptCtxCancel()
+	tasks := b.drainQueue()
+	notifyDiscarded(tasks...)
+	summary := b.shutdownSummary(completed, failed)
+	summary.Dropped = len(tasks)
+	summary.Interrupted = len(interrupted)
+	return append(tasks, interrupted...), summary, ctx.Err()
+}
+
+// runningTaskList 返回所有正在执行的任务
+func (b *OnDemandBlockTaskPool) runningTaskList() []Task {
+	var tasks []Task
+	b.runningTasks.Range(func(key, value any) bool {
+		tasks = append(tasks, value.(Task))
+		return true
+	})
+	return tasks
+}
+
+func (b *OnDemandBlockTaskPool) shutdownSummary(completed, failed int64) ShutdownSummary {
+	return ShutdownSummary{
+		Completed: atomic.LoadInt64(&b.completedCnt) - completed,
+		Failed:    atomic.LoadInt64(&b.failedCnt) - failed,
+	}
+}
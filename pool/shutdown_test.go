@@ -0,0 +1,111 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnDemandBlockTaskPool_ShutdownWithContext(t *testing.T) {
+	t.Parallel()
+
+	t.Run("状态不正确", func(t *testing.T) {
+		t.Parallel()
+
+		p, err := NewOnDemandBlockTaskPool(1, 1)
+		require.NoError(t, err)
+		_, _, err = p.ShutdownWithContext(context.Background())
+		assert.ErrorIs(t, err, errTaskPoolIsNotRunning)
+
+		p = testNewStoppedStateTaskPool(t, 1, 1)
+		_, _, err = p.ShutdownWithContext(context.Background())
+		assert.ErrorIs(t, err, errTaskPoolIsStopped)
+	})
+
+	t.Run("在ctx过期前执行完毕", func(t *testing.T) {
+		t.Parallel()
+
+		p := testNewRunningStateTaskPool(t, 2, 10)
+		for i := 0; i < 6; i++ {
+			i := i
+			require.NoError(t, p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error {
+				time.Sleep(time.Millisecond)
+				switch i {
+				case 0:
+					return errors.New("mock error")
+				case 1:
+					panic("mock panic")
+				}
+				return nil
+			})))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		tasks, summary, err := p.ShutdownWithContext(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, tasks)
+		assert.Equal(t, ShutdownSummary{Completed: 4, Failed: 2}, summary)
+		assert.Equal(t, stateStopped, p.internalState())
+	})
+
+	t.Run("ctx过期后中断", func(t *testing.T) {
+		t.Parallel()
+
+		p := testNewRunningStateTaskPool(t, 1, 10)
+		running := make(chan struct{})
+		interrupted := make(chan struct{})
+		blocked := &shutdownTestTask{run: func(ctx context.Context) error {
+			close(running)
+			<-ctx.Done()
+			close(interrupted)
+			return ctx.Err()
+		}}
+		require.NoError(t, p.Submit(context.Background(), blocked))
+		<-running
+		for i := 0; i < 3; i++ {
+			require.NoError(t, p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error { return nil })))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		tasks, summary, err := p.ShutdownWithContext(ctx)
+		assert.Equal(t, context.DeadlineExceeded, err)
+		<-interrupted
+		// 尚未开始执行的任务在前，被中断的任务在后
+		require.Equal(t, 4, len(tasks))
+		assert.Equal(t, blocked, unwrapTask(tasks[3]))
+		assert.Equal(t, ShutdownSummary{Dropped: 3, Interrupted: 1}, summary)
+		assert.Equal(t, stateStopped, p.internalState())
+
+		_, _, err = p.ShutdownWithContext(context.Background())
+		assert.ErrorIs(t, err, errTaskPoolIsStopped)
+	})
+}
+
+// shutdownTestTask 可以比较是否为同一个任务，TaskFunc 做不到这一点
+type shutdownTestTask struct {
+	run TaskFunc
+}
+
+func (s *shutdownTestTask) Run(ctx context.Context) error {
+	return s.run(ctx)
+}
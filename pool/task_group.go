@@ -0,0 +1,146 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ecodeclub/ekit/bean/option"
+)
+
+// TaskGroup 将一组任务提交到 TaskPool 中执行，并等待它们全部结束
+// 用法类似于 errgroup.Group，区别在于任务是由 TaskPool 执行的
+type TaskGroup struct {
+	pool   TaskPool
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	cancelOnError bool
+
+	wg    sync.WaitGroup
+	mutex sync.Mutex
+	// 下一个任务的序号
+	idx  int
+	errs []error
+}
+
+// NewTaskGroup 创建一个 TaskGroup
+// 返回的 context.Context 派生自 ctx，它会在 Wait 返回时被取消，
+// 如果设置了 WithCancelOnFirstError，那么它也会在第一个任务失败时被取消
+func NewTaskGroup(ctx context.Context, p TaskPool, opts ...option.Option[TaskGroup]) (*TaskGroup, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	g := &TaskGroup{
+		pool:   p,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	option.Apply(g, opts...)
+	return g, ctx
+}
+
+// WithCancelOnFirstError 第一个任务失败时取消 TaskGroup 的 context
+func WithCancelOnFirstError() option.Option[TaskGroup] {
+	return func(g *TaskGroup) {
+		g.cancelOnError = true
+	}
+}
+
+// Go 提交一个任务
+// 任务执行时收到的 context.Context 在 NewTaskGroup 返回的 context.Context 或者 TaskPool 传入的 context.Context
+// 任意一个被取消时都会被取消，所以 ShutdownNow 依旧可以中断 TaskGroup 中的任务
+// 提交的语义与 TaskPool.Submit 一致，提交失败时返回 error，并且该任务不会被 Wait 等待
+func (g *TaskGroup) Go(task Task) error {
+	if task == nil {
+		return fmt.Errorf("%w", errTaskIsInvalid)
+	}
+	g.mutex.Lock()
+	idx := g.idx
+	g.idx++
+	g.mutex.Unlock()
+
+	g.wg.Add(1)
+	err := g.pool.Submit(g.ctx, &groupTask{g: g, task: task, idx: idx})
+	if err != nil {
+		g.wg.Done()
+	}
+	return err
+}
+
+// groupTask 是 TaskGroup 提交到 TaskPool 中的任务
+// 无论是被执行还是被丢弃，都只会结束一次
+type groupTask struct {
+	g    *TaskGroup
+	task Task
+	idx  int
+	// 为 1 表示已经开始执行或者已经被丢弃
+	finished int32
+}
+
+func (t *groupTask) Run(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&t.finished, 0, 1) {
+		return fmt.Errorf("%w", ErrTaskDiscarded)
+	}
+	defer t.g.wg.Done()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-t.g.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	// 复用 taskWrapper 将 panic 转化为 errTaskRunningPanic
+	err := (&taskWrapper{t: t.task}).Run(ctx)
+	if err != nil {
+		t.g.addErr(fmt.Errorf("ekit: 第 %d 个任务执行失败 %w", t.idx, err))
+	}
+	return err
+}
+
+// discarded 任务没有执行就被丢弃，Wait 不再等待它，并且会返回 ErrTaskDiscarded
+func (t *groupTask) discarded() {
+	if !atomic.CompareAndSwapInt32(&t.finished, 0, 1) {
+		return
+	}
+	t.g.addErr(fmt.Errorf("ekit: 第 %d 个任务未执行 %w", t.idx, ErrTaskDiscarded))
+	t.g.wg.Done()
+}
+
+func (g *TaskGroup) addErr(err error) {
+	g.mutex.Lock()
+	g.errs = append(g.errs, err)
+	g.mutex.Unlock()
+	if g.cancelOnError {
+		g.cancel()
+	}
+}
+
+// Wait 等待所有提交成功的任务结束
+// 返回的 error 由所有失败任务的 error 通过 errors.Join 组合而成，
+// 调用者可以使用 errors.Is 或者 errors.As 判断其中的每一个 error
+// 被 ShutdownNow 或者 DiscardPolicy、DiscardOldestPolicy 丢弃的任务不会被等待，对应的 error 为 ErrTaskDiscarded
+// 注意：如果自定义的 RejectPolicy 静默丢弃了任务，那么 Wait 将无法返回
+func (g *TaskGroup) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return errors.Join(g.errs...)
+}
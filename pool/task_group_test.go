@@ -0,0 +1,178 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskGroup_Wait(t *testing.T) {
+	t.Parallel()
+
+	errMock := errors.New("mock error")
+	testCases := []struct {
+		name    string
+		tasks   []TaskFunc
+		wantErr []error
+	}{
+		{
+			name: "全部成功",
+			tasks: []TaskFunc{
+				func(ctx context.Context) error { return nil },
+				func(ctx context.Context) error { return nil },
+			},
+		},
+		{
+			name: "部分失败",
+			tasks: []TaskFunc{
+				func(ctx context.Context) error { return nil },
+				func(ctx context.Context) error { return errMock },
+				func(ctx context.Context) error { panic("mock panic") },
+			},
+			wantErr: []error{errMock, errTaskRunningPanic},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			p := testNewRunningStateTaskPool(t, 2, 10)
+			g, _ := NewTaskGroup(context.Background(), p)
+			var cnt int32
+			for _, task := range tc.tasks {
+				task := task
+				require.NoError(t, g.Go(TaskFunc(func(ctx context.Context) error {
+					atomic.AddInt32(&cnt, 1)
+					return task(ctx)
+				})))
+			}
+			err := g.Wait()
+			assert.Equal(t, int32(len(tc.tasks)), atomic.LoadInt32(&cnt))
+			if len(tc.wantErr) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			for _, wantErr := range tc.wantErr {
+				assert.ErrorIs(t, err, wantErr)
+			}
+			assert.Equal(t, len(tc.wantErr), len(err.(interface{ Unwrap() []error }).Unwrap()))
+		})
+	}
+}
+
+func TestTaskGroup_CancelOnFirstError(t *testing.T) {
+	t.Parallel()
+
+	p := testNewRunningStateTaskPool(t, 2, 10)
+	g, ctx := NewTaskGroup(context.Background(), p, WithCancelOnFirstError())
+	errMock := errors.New("mock error")
+
+	require.NoError(t, g.Go(TaskFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})))
+	require.NoError(t, g.Go(TaskFunc(func(ctx context.Context) error {
+		return errMock
+	})))
+
+	err := g.Wait()
+	assert.ErrorIs(t, err, errMock)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, context.Canceled, ctx.Err())
+}
+
+func TestTaskGroup_Go(t *testing.T) {
+	t.Parallel()
+
+	g, _ := NewTaskGroup(context.Background(), testNewStoppedStateTaskPool(t, 1, 1))
+	assert.ErrorIs(t, g.Go(nil), errTaskIsInvalid)
+	assert.ErrorIs(t, g.Go(TaskFunc(func(ctx context.Context) error { return nil })), errTaskPoolIsStopped)
+	assert.NoError(t, g.Wait())
+}
+
+func TestTaskGroup_ShutdownNow(t *testing.T) {
+	t.Parallel()
+
+	p := testNewRunningStateTaskPool(t, 1, 10)
+	g, _ := NewTaskGroup(context.Background(), p)
+	running := make(chan struct{})
+	// 正在执行的任务可以被 ShutdownNow 中断
+	require.NoError(t, g.Go(TaskFunc(func(ctx context.Context) error {
+		close(running)
+		<-ctx.Done()
+		return ctx.Err()
+	})))
+	<-running
+	// 等待中的任务被丢弃
+	var cnt int32
+	require.NoError(t, g.Go(TaskFunc(func(ctx context.Context) error {
+		atomic.AddInt32(&cnt, 1)
+		return nil
+	})))
+
+	tasks, err := p.ShutdownNow()
+	require.NoError(t, err)
+	err = g.Wait()
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, err, ErrTaskDiscarded)
+
+	// 被丢弃的任务再次执行不会有任何效果
+	require.Equal(t, 1, len(tasks))
+	assert.ErrorIs(t, tasks[0].Run(context.Background()), ErrTaskDiscarded)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&cnt))
+}
+
+func TestTaskGroup_Discarded(t *testing.T) {
+	t.Parallel()
+
+	// 未启动的 TaskPool 中队列满了之后不会有任务被取走
+	p, err := NewOnDemandBlockTaskPool(1, 1, WithRejectPolicy(DiscardPolicy{}))
+	require.NoError(t, err)
+	g, _ := NewTaskGroup(context.Background(), p)
+	task := TaskFunc(func(ctx context.Context) error { return nil })
+	require.NoError(t, g.Go(task))
+	require.NoError(t, g.Go(task))
+
+	require.NoError(t, p.Start())
+	err = g.Wait()
+	assert.ErrorIs(t, err, ErrTaskDiscarded)
+	assert.Equal(t, "ekit: 第 1 个任务未执行 ekit: 任务未执行就被丢弃", err.Error())
+}
+
+func ExampleTaskGroup() {
+	p, _ := NewOnDemandBlockTaskPool(2, 10)
+	_ = p.Start()
+	g, _ := NewTaskGroup(context.Background(), p)
+	for i := 0; i < 3; i++ {
+		i := i
+		_ = g.Go(TaskFunc(func(ctx context.Context) error {
+			if i == 1 {
+				return errors.New("mock error")
+			}
+			return nil
+		}))
+	}
+	fmt.Println(g.Wait())
+	// Output:
+	// ekit: 第 1 个任务执行失败 mock error
+}
@@ -24,6 +24,7 @@ import (
 	"time"
 
 	"github.com/ecodeclub/ekit/bean/option"
+	"github.com/ecodeclub/ekit/queue"
 )
 
 var (
@@ -58,20 +59,42 @@ func (t TaskFunc) Run(ctx context.Context) error { return t(ctx) }
 // taskWrapper 是Task的装饰器
 type taskWrapper struct {
 	t Task
+
+	// 以下字段只有设置了 Observer 才会被使用
+	observer    Observer
+	submittedAt time.Time
 }
 
 func (tw *taskWrapper) Run(ctx context.Context) (err error) {
+	if tw.observer != nil {
+		start := time.Now()
+		tw.observer.OnTaskStarted(tw.t, start.Sub(tw.submittedAt))
+		defer func() {
+			tw.observer.OnTaskFinished(tw.t, time.Since(start), err)
+		}()
+	}
 	defer func() {
 		// 处理 panic
 		if r := recover(); r != nil {
 			buf := make([]byte, panicBuffLen)
 			buf = buf[:runtime.Stack(buf, false)]
 			err = fmt.Errorf("%w：%s", errTaskRunningPanic, fmt.Sprintf("[PANIC]:\t%+v\n%s\n", r, buf))
+			if tw.observer != nil {
+				tw.observer.OnTaskPanicked(tw.t, r)
+			}
 		}
 	}()
 	return tw.t.Run(ctx)
 }
 
+// unwrapTask 返回用户提交的原始任务
+func unwrapTask(task Task) Task {
+	if tw, ok := task.(*taskWrapper); ok {
+		return tw.t
+	}
+	return task
+}
+
 type group struct {
 	mp map[int]int
 	n  int32
@@ -116,6 +139,8 @@ type OnDemandBlockTaskPool struct {
 
 	queue             chan Task
 	numGoRunningTasks int32
+	// 工作协程 id 到其正在执行的任务的映射，用于 ShutdownWithContext 返回被中断的任务
+	runningTasks sync.Map
 
 	totalGo int32
 	mutex   sync.RWMutex
@@ -132,6 +157,9 @@ type OnDemandBlockTaskPool struct {
 	maxIdleTime time.Duration
 	// 队列积压率
 	queueBacklogRate float64
+	// 调小 maxGo 时通过关闭该 chan 唤醒空闲协程退出
+	// 工作协程每次循环都需要读取，所以使用原子操作而不是 mutex
+	shrinkSignal atomic.Pointer[chan struct{}]
 
 	// 协程id方便调试程序
 	id int32
@@ -139,6 +167,25 @@ type OnDemandBlockTaskPool struct {
 	// 中断信号
 	interruptCtx       context.Context
 	interruptCtxCancel context.CancelFunc
+
+	// 优先级队列，只有开启优先级模式时才不为 nil
+	priorityQueue *queue.ConcurrentPriorityQueue[*priorityTask]
+	// 老化时间
+	priorityAging time.Duration
+	// 优先级任务的提交序号
+	prioritySeq int64
+	// 各优先级等待中的任务数
+	priorityWaiting *priorityCounter
+
+	// 拒绝策略，为 nil 时队列已满会阻塞调用者
+	rejectPolicy RejectPolicy
+	// 被拒绝的任务数
+	rejectedCnt int64
+	// 执行成功和执行失败的任务数
+	completedCnt int64
+	failedCnt    int64
+
+	observer Observer
 }
 
 // NewOnDemandBlockTaskPool 创建一个新的 OnDemandBlockTaskPool
@@ -159,6 +206,8 @@ func NewOnDemandBlockTaskPool(initGo int, queueSize int, opts ...option.Option[O
 		maxGo:       int32(initGo),
 		maxIdleTime: defaultMaxIdleTime,
 	}
+	shrinkSignal := make(chan struct{})
+	b.shrinkSignal.Store(&shrinkSignal)
 	ctx := context.Background()
 	b.interruptCtx, b.interruptCtxCancel = context.WithCancel(ctx)
 	atomic.StoreInt32(&b.state, stateCreated)
@@ -179,6 +228,15 @@ func NewOnDemandBlockTaskPool(initGo int, queueSize int, opts ...option.Option[O
 	if b.queueBacklogRate < float64(0) || float64(1) < b.queueBacklogRate {
 		return nil, fmt.Errorf("%w ：queueBacklogRate合法范围为[0,1.0]", errInvalidArgument)
 	}
+
+	if b.priorityQueue != nil {
+		if queueSize == 0 {
+			return nil, fmt.Errorf("%w ：优先级模式下queueSize应该大于0", errInvalidArgument)
+		}
+		if b.priorityAging < 0 {
+			return nil, fmt.Errorf("%w ：老化时间应该大于等于0", errInvalidArgument)
+		}
+	}
 	return b, nil
 }
 
@@ -207,13 +265,22 @@ func WithMaxIdleTime(d time.Duration) option.Option[OnDemandBlockTaskPool] {
 }
 
 // Submit 提交一个任务
-// 如果此时队列已满，那么将会阻塞调用者。
+// 如果此时队列已满，那么将会阻塞调用者，设置了拒绝策略时则交由拒绝策略处理。
 // 如果因为 ctx 的原因返回，那么将会返回 ctx.Err()
 // 在调用 Start 前后都可以调用 Submit
+// 开启优先级模式时，实现了 PriorityTask 的任务按照其优先级调度，其余任务的优先级为 0
 func (b *OnDemandBlockTaskPool) Submit(ctx context.Context, task Task) error {
 	if task == nil {
 		return fmt.Errorf("%w", errTaskIsInvalid)
 	}
+	if b.priorityQueue != nil {
+		priority := 0
+		if pt, ok := task.(PriorityTask); ok {
+			priority = pt.Priority()
+		}
+		return b.submitWithPriority(ctx, task, priority)
+	}
+	task = b.wrapTask(task)
 	// todo: 用户未设置超时，可以考虑内部给个超时提交
 	for {
 
@@ -225,17 +292,34 @@ func (b *OnDemandBlockTaskPool) Submit(ctx context.Context, task Task) error {
 			return fmt.Errorf("%w", errTaskPoolIsStopped)
 		}
 
-		task = &taskWrapper{t: task}
-
-		ok, err := b.trySubmit(ctx, task, stateCreated)
-		if ok || err != nil {
-			return err
+		for _, state := range [...]int32{stateCreated, stateRunning} {
+			ok, err := b.trySubmit(ctx, task, state)
+			if ok {
+				b.notifySubmitted(task)
+				return nil
+			}
+			if err == errTaskQueueIsFull {
+				ok, err = b.reject(ctx, task, state, func() { b.queue <- task }, nil)
+			}
+			if ok || err != nil {
+				return err
+			}
 		}
+	}
+}
 
-		ok, err = b.trySubmit(ctx, task, stateRunning)
-		if ok || err != nil {
-			return err
-		}
+func (b *OnDemandBlockTaskPool) wrapTask(task Task) *taskWrapper {
+	tw := &taskWrapper{t: task}
+	if b.observer != nil {
+		tw.observer = b.observer
+		tw.submittedAt = time.Now()
+	}
+	return tw
+}
+
+func (b *OnDemandBlockTaskPool) notifySubmitted(task Task) {
+	if b.observer != nil {
+		b.observer.OnTaskSubmitted(unwrapTask(task))
 	}
 }
 
@@ -251,21 +335,30 @@ func (b *OnDemandBlockTaskPool) trySubmit(ctx context.Context, task Task, state
 		case <-ctx.Done():
 			return false, fmt.Errorf("%w", ctx.Err())
 		case b.queue <- task:
-			if state == stateRunning && b.allowToCreateGoroutine() {
-				b.increaseTotalGo(1)
-				id := int(atomic.AddInt32(&b.id, 1))
-				go b.goroutine(id)
-				// log.Println("create go ", id)
-			}
+			b.tryCreateGoroutine(state)
 			return true, nil
 		default:
 			// 不能阻塞在临界区,要给Shutdown和ShutdownNow机会
+			// 设置了拒绝策略则交由拒绝策略处理
+			if b.rejectPolicy != nil {
+				return false, errTaskQueueIsFull
+			}
 			return false, nil
 		}
 	}
 	return false, nil
 }
 
+// tryCreateGoroutine 在任务入队之后按需创建工作协程
+func (b *OnDemandBlockTaskPool) tryCreateGoroutine(state int32) {
+	if state == stateRunning && b.allowToCreateGoroutine() {
+		b.increaseTotalGo(1)
+		id := int(atomic.AddInt32(&b.id, 1))
+		go b.goroutine(id)
+		// log.Println("create go ", id)
+	}
+}
+
 func (b *OnDemandBlockTaskPool) allowToCreateGoroutine() bool {
 	b.mutex.RLock()
 	defer b.mutex.RUnlock()
@@ -306,6 +399,8 @@ func (b *OnDemandBlockTaskPool) Start() error {
 }
 
 func (b *OnDemandBlockTaskPool) numOfGoThatCanBeCreate() int32 {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
 	n := b.initGo
 	allowGo := b.maxGo - b.initGo
 	needGo := int32(len(b.queue)) - b.initGo
@@ -320,6 +415,10 @@ func (b *OnDemandBlockTaskPool) numOfGoThatCanBeCreate() int32 {
 }
 
 func (b *OnDemandBlockTaskPool) goroutine(id int) {
+	if b.observer != nil {
+		b.observer.OnGoroutineCreated(id)
+		defer b.observer.OnGoroutineReclaimed(id)
+	}
 
 	// 刚启动的协程除非恰巧赶上Shutdown/ShutdownNow被调用，否则应该至少执行一个task
 	idleTimer := time.NewTimer(0)
@@ -330,6 +429,17 @@ func (b *OnDemandBlockTaskPool) goroutine(id int) {
 	for {
 		// log.Println("id", id, "working for loop")
 		select {
+		case <-*b.shrinkSignal.Load():
+			b.mutex.Lock()
+			if b.totalGo > b.maxGo {
+				// 运行期间调小了 maxGo，空闲的协程直接退出
+				b.totalGo--
+				b.timeoutGroup.delete(id)
+				b.mutex.Unlock()
+				idleTimer.Stop()
+				return
+			}
+			b.mutex.Unlock()
 		case <-b.interruptCtx.Done():
 			// log.Printf("id %d shutdownNow, timeoutGroup.Size=%d left\n", id, b.timeoutGroup.size())
 			b.decreaseTotalGo(1)
@@ -364,16 +474,26 @@ func (b *OnDemandBlockTaskPool) goroutine(id int) {
 				return
 			}
 
-			// todo handle error
+			if _, isTicket := task.(priorityTicket); isTicket {
+				// 先取出真正要执行的任务，这样记录下来的才是用户提交的任务
+				task = b.dequeuePriorityTask()
+			}
 			atomic.AddInt32(&b.numGoRunningTasks, 1)
-			_ = task.Run(b.interruptCtx)
+			b.runningTasks.Store(id, task)
+			if err := task.Run(b.interruptCtx); err != nil {
+				atomic.AddInt64(&b.failedCnt, 1)
+			} else {
+				atomic.AddInt64(&b.completedCnt, 1)
+			}
+			b.runningTasks.Delete(id)
 			atomic.AddInt32(&b.numGoRunningTasks, -1)
 
 			b.mutex.Lock()
 			// log.Println("id", id, "totalGo-mem", b.totalGo-b.timeoutGroup.size(), "totalGo", b.totalGo, "mem", b.timeoutGroup.size())
 			noTasksToExecute := len(b.queue) == 0 || int32(len(b.queue)) < b.totalGo
-			if b.coreGo < b.totalGo && b.totalGo <= b.maxGo && noTasksToExecute {
+			if b.coreGo < b.totalGo && (b.totalGo > b.maxGo || noTasksToExecute) {
 				// 当前协程属于(coreGo,maxGo]区间，发现没有任务可以执行故直接退出
+				// 运行期间调小了 maxGo 导致当前协程超出 maxGo 时也直接退出
 				// 注意：一定要在此处减1才能让此刻等待在mutex上的其他协程被正确地划分区间
 				b.totalGo--
 				// log.Println("id", id, "exits....")
@@ -399,6 +519,83 @@ func (b *OnDemandBlockTaskPool) goroutine(id int) {
 	}
 }
 
+// SetCoreGo 在运行期间调整核心协程数，需要满足 initGo <= n <= maxGo
+// 调小之后，超出部分的协程会在执行完手头的任务或者空闲超时后退出
+func (b *OnDemandBlockTaskPool) SetCoreGo(n int32) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if n < b.initGo || n > b.maxGo {
+		return fmt.Errorf("%w : 需要满足initGo <= coreGo <= maxGo条件", errInvalidArgument)
+	}
+	b.coreGo = n
+	return nil
+}
+
+// SetMaxGo 在运行期间调整最大协程数，需要满足 coreGo <= n
+// 调大之后，如果队列中有积压的任务，会立刻按需创建协程；
+// 调小之后，空闲的协程会立刻退出，正在执行任务的协程会在执行完毕之后退出
+func (b *OnDemandBlockTaskPool) SetMaxGo(n int32) error {
+	b.mutex.Lock()
+	if n < b.coreGo {
+		b.mutex.Unlock()
+		return fmt.Errorf("%w : 需要满足initGo <= coreGo <= maxGo条件", errInvalidArgument)
+	}
+	shrink := n < b.maxGo
+	b.maxGo = n
+	if shrink {
+		// 先换上新的 chan 再关闭旧的，被唤醒的协程不会再次读到已关闭的 chan
+		shrinkSignal := make(chan struct{})
+		close(*b.shrinkSignal.Swap(&shrinkSignal))
+	}
+	b.mutex.Unlock()
+	if !shrink {
+		b.expandGo()
+	}
+	return nil
+}
+
+// expandGo 调大 maxGo 之后，为队列中积压的任务创建协程
+func (b *OnDemandBlockTaskPool) expandGo() {
+	for {
+		state := atomic.LoadInt32(&b.state)
+		if state != stateRunning && state != stateLocked {
+			// 尚未启动的 TaskPool 会在 Start 的时候创建协程，关闭中的 TaskPool 不需要新的协程
+			return
+		}
+		if atomic.CompareAndSwapInt32(&b.state, stateRunning, stateLocked) {
+			n := len(b.queue)
+			for i := 0; i < n && b.allowToCreateGoroutine(); i++ {
+				b.increaseTotalGo(1)
+				go b.goroutine(int(atomic.AddInt32(&b.id, 1)))
+			}
+			atomic.CompareAndSwapInt32(&b.state, stateLocked, stateRunning)
+			return
+		}
+	}
+}
+
+// SetMaxIdleTime 在运行期间调整最大空闲时间，只对之后进入空闲状态的协程生效
+func (b *OnDemandBlockTaskPool) SetMaxIdleTime(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("%w ：maxIdleTime应该大于0", errInvalidArgument)
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.maxIdleTime = d
+	return nil
+}
+
+// SetQueueBacklogRate 在运行期间调整队列积压率
+func (b *OnDemandBlockTaskPool) SetQueueBacklogRate(rate float64) error {
+	if rate < float64(0) || float64(1) < rate {
+		return fmt.Errorf("%w ：queueBacklogRate合法范围为[0,1.0]", errInvalidArgument)
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.queueBacklogRate = rate
+	return nil
+}
+
 func (b *OnDemandBlockTaskPool) increaseTotalGo(n int32) {
 	b.mutex.Lock()
 	b.totalGo += n
@@ -445,6 +642,7 @@ func (b *OnDemandBlockTaskPool) Shutdown() (<-chan struct{}, error) {
 }
 
 // ShutdownNow 立刻关闭任务池，并且返回所有剩余未执行的任务（不包含正在执行的任务）
+// 返回的任务中，TaskGroup 等需要感知丢弃的任务会以 ErrTaskDiscarded 结束，再次执行它们不会有任何效果
 func (b *OnDemandBlockTaskPool) ShutdownNow() ([]Task, error) {
 
 	for {
@@ -471,15 +669,25 @@ func (b *OnDemandBlockTaskPool) ShutdownNow() ([]Task, error) {
 			b.interruptCtxCancel()
 
 			// 清空队列并保存
-			tasks := make([]Task, 0, len(b.queue))
-			for task := range b.queue {
-				tasks = append(tasks, task)
-			}
+			tasks := b.drainQueue()
+			notifyDiscarded(tasks...)
 			return tasks, nil
 		}
 	}
 }
 
+// drainQueue 取出已关闭的 b.queue 中剩余的所有任务
+func (b *OnDemandBlockTaskPool) drainQueue() []Task {
+	tasks := make([]Task, 0, len(b.queue))
+	for task := range b.queue {
+		if _, ok := task.(priorityTicket); ok {
+			task = b.dequeuePriorityTask()
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
 // internalState 用于查看TaskPool状态
 func (b *OnDemandBlockTaskPool) internalState() int32 {
 	for {
@@ -539,12 +747,16 @@ func (b *OnDemandBlockTaskPool) sendState(ch chan<- State, timeStamp int64) {
 
 func (b *OnDemandBlockTaskPool) getState(timeStamp int64) State {
 	s := State{
-		PoolState:       atomic.LoadInt32(&b.state),
-		GoCnt:           b.numOfGo(),
-		QueueSize:       cap(b.queue),
-		WaitingTasksCnt: len(b.queue),
-		RunningTasksCnt: atomic.LoadInt32(&b.numGoRunningTasks),
-		Timestamp:       timeStamp,
+		PoolState:        atomic.LoadInt32(&b.state),
+		GoCnt:            b.numOfGo(),
+		QueueSize:        cap(b.queue),
+		WaitingTasksCnt:  len(b.queue),
+		RunningTasksCnt:  atomic.LoadInt32(&b.numGoRunningTasks),
+		RejectedTasksCnt: atomic.LoadInt64(&b.rejectedCnt),
+		Timestamp:        timeStamp,
+	}
+	if b.priorityWaiting != nil {
+		s.WaitingTasksCntByPriority = b.priorityWaiting.snapshot()
 	}
 	return s
 }
@@ -19,6 +19,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -1145,6 +1146,75 @@ func TestGroup(t *testing.T) {
 	assert.Equal(t, int32(0), g.size())
 }
 
+func TestOnDemandBlockTaskPool_Resize(t *testing.T) {
+	t.Parallel()
+
+	t.Run("参数非法", func(t *testing.T) {
+		t.Parallel()
+		pool, err := NewOnDemandBlockTaskPool(2, 3, WithCoreGo(3), WithMaxGo(4))
+		assert.NoError(t, err)
+		assert.ErrorIs(t, pool.SetCoreGo(1), errInvalidArgument)
+		assert.ErrorIs(t, pool.SetCoreGo(5), errInvalidArgument)
+		assert.ErrorIs(t, pool.SetMaxGo(2), errInvalidArgument)
+		assert.ErrorIs(t, pool.SetMaxIdleTime(0), errInvalidArgument)
+		assert.ErrorIs(t, pool.SetQueueBacklogRate(1.1), errInvalidArgument)
+
+		assert.NoError(t, pool.SetCoreGo(4))
+		assert.NoError(t, pool.SetMaxIdleTime(time.Second))
+		assert.NoError(t, pool.SetQueueBacklogRate(0.5))
+		assert.Equal(t, int32(4), pool.coreGo)
+		assert.Equal(t, time.Second, pool.maxIdleTime)
+		assert.Equal(t, 0.5, pool.queueBacklogRate)
+	})
+
+	t.Run("调大maxGo时为积压的任务创建协程", func(t *testing.T) {
+		t.Parallel()
+		pool, wait := testNewRunningStateTaskPoolWithQueueFullFilled(t, 1, 5)
+		assert.Equal(t, int32(1), pool.numOfGo())
+
+		assert.NoError(t, pool.SetMaxGo(10))
+		assert.Equal(t, int32(6), pool.numOfGo())
+
+		close(wait)
+		done, err := pool.Shutdown()
+		assert.NoError(t, err)
+		<-done
+		assert.Equal(t, int32(0), pool.numOfGo())
+	})
+
+	t.Run("调小maxGo时空闲协程退出", func(t *testing.T) {
+		t.Parallel()
+		pool := testNewRunningStateTaskPool(t, 1, 5, WithMaxGo(6), WithMaxIdleTime(time.Hour))
+		wait := make(chan struct{})
+		for i := 0; i < 6; i++ {
+			err := pool.Submit(context.Background(), TaskFunc(func(ctx context.Context) error {
+				<-wait
+				return nil
+			}))
+			assert.NoError(t, err)
+		}
+		assert.Eventually(t, func() bool {
+			return pool.numOfGo() == 6
+		}, time.Second, time.Millisecond)
+
+		close(wait)
+		assert.Eventually(t, func() bool {
+			return atomic.LoadInt32(&pool.numGoRunningTasks) == 0
+		}, time.Second, time.Millisecond)
+		// 空闲超时时间很长，协程不会自己退出
+		assert.Equal(t, int32(6), pool.numOfGo())
+
+		assert.NoError(t, pool.SetCoreGo(2))
+		assert.NoError(t, pool.SetMaxGo(2))
+		assert.Eventually(t, func() bool {
+			return pool.numOfGo() == 2
+		}, time.Second, time.Millisecond)
+
+		_, err := pool.ShutdownNow()
+		assert.NoError(t, err)
+	})
+}
+
 func ExampleNewOnDemandBlockTaskPool() {
 	p, _ := NewOnDemandBlockTaskPool(10, 100)
 	_ = p.Start()
@@ -62,5 +62,9 @@ type State struct {
 	WaitingTasksCnt int
 	QueueSize       int
 	RunningTasksCnt int32
-	Timestamp       int64
+	// RejectedTasksCnt 被拒绝策略处理的任务总数
+	RejectedTasksCnt int64
+	Timestamp        int64
+	// WaitingTasksCntByPriority 各优先级等待中的任务数，只有开启优先级模式时才不为 nil
+	WaitingTasksCntByPriority map[int]int
 }
@@ -0,0 +1,415 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var _ TaskPool = &WorkStealingTaskPool{}
+
+// WorkStealingTaskPool 基于工作窃取的任务池
+// 每个工作协程都有自己的本地队列，Submit 按照轮询的方式把任务放入各个本地队列，
+// 工作协程优先执行本地队列中的任务，本地队列为空时从其它工作协程的队列尾部窃取任务。
+// 相比 OnDemandBlockTaskPool 共享一个 chan，在大量短任务、多核的场景下竞争更小。
+// 工作协程的数量是固定的，不会按需扩缩容
+type WorkStealingTaskPool struct {
+	// 保护 state 的迁移，Submit 持有读锁，状态迁移持有写锁
+	mutex sync.RWMutex
+	state int32
+
+	queues []*taskDeque
+	// 下一个放入任务的本地队列
+	next uint32
+
+	// 有新任务时放入一个信号唤醒空闲的工作协程
+	notify chan struct{}
+	// 有任务被取走时唤醒因为队列已满而阻塞的 Submit
+	notFull *broadcaster
+	// Shutdown 时关闭，通知工作协程清空队列后退出
+	closing chan struct{}
+
+	numGo             int32
+	numGoRunningTasks int32
+
+	interruptCtx       context.Context
+	interruptCtxCancel context.CancelFunc
+}
+
+// NewWorkStealingTaskPool 创建一个 WorkStealingTaskPool
+// workers 是工作协程数
+// queueSizePerWorker 是每个工作协程本地队列的大小，所以最多有 workers * queueSizePerWorker 个任务在等待调度
+func NewWorkStealingTaskPool(workers int, queueSizePerWorker int) (*WorkStealingTaskPool, error) {
+	if workers < 1 {
+		return nil, fmt.Errorf("%w：workers应该大于0", errInvalidArgument)
+	}
+	if queueSizePerWorker < 1 {
+		return nil, fmt.Errorf("%w：queueSizePerWorker应该大于0", errInvalidArgument)
+	}
+	p := &WorkStealingTaskPool{
+		state:   stateCreated,
+		queues:  make([]*taskDeque, workers),
+		notify:  make(chan struct{}, workers*queueSizePerWorker),
+		notFull: newBroadcaster(),
+		closing: make(chan struct{}),
+	}
+	for i := range p.queues {
+		p.queues[i] = newTaskDeque(queueSizePerWorker)
+	}
+	p.interruptCtx, p.interruptCtxCancel = context.WithCancel(context.Background())
+	return p, nil
+}
+
+// Submit 提交一个任务
+// 如果所有的本地队列都已满，那么将会阻塞调用者，直到 ctx 过期
+// 在调用 Start 前后都可以调用 Submit
+func (p *WorkStealingTaskPool) Submit(ctx context.Context, task Task) error {
+	if task == nil {
+		return fmt.Errorf("%w", errTaskIsInvalid)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	task = &taskWrapper{t: task}
+	// 大多数情况下队列没有满，不需要经过 notFull
+	if ok, err := p.trySubmit(task); ok || err != nil {
+		return err
+	}
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		ok, err := p.waitAndTrySubmit(ctx, task)
+		if ok || err != nil {
+			return err
+		}
+	}
+}
+
+// waitAndTrySubmit 登记为等待者之后再尝试放入一次，失败的话等待有任务被取走
+func (p *WorkStealingTaskPool) waitAndTrySubmit(ctx context.Context, task Task) (bool, error) {
+	// 必须在尝试放入之前登记，否则可能错过放入失败之后的唤醒
+	signal := p.notFull.wait()
+	defer p.notFull.done()
+	ok, err := p.trySubmit(task)
+	if ok || err != nil {
+		return ok, err
+	}
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-signal:
+		return false, nil
+	}
+}
+
+func (p *WorkStealingTaskPool) trySubmit(task Task) (bool, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	switch p.state {
+	case stateClosing:
+		return false, fmt.Errorf("%w", errTaskPoolIsClosing)
+	case stateStopped:
+		return false, fmt.Errorf("%w", errTaskPoolIsStopped)
+	}
+	n := uint32(len(p.queues))
+	start := atomic.AddUint32(&p.next, 1)
+	for i := uint32(0); i < n; i++ {
+		if p.queues[(start+i)%n].pushBack(task) {
+			select {
+			case p.notify <- struct{}{}:
+			default:
+				// 信号已经足够多了，空闲的工作协程一定会被唤醒
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Start 开始调度任务执行
+func (p *WorkStealingTaskPool) Start() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	switch p.state {
+	case stateRunning:
+		return fmt.Errorf("%w", errTaskPoolIsStarted)
+	case stateClosing:
+		return fmt.Errorf("%w", errTaskPoolIsClosing)
+	case stateStopped:
+		return fmt.Errorf("%w", errTaskPoolIsStopped)
+	}
+	p.state = stateRunning
+	atomic.StoreInt32(&p.numGo, int32(len(p.queues)))
+	for i := range p.queues {
+		go p.worker(i)
+	}
+	return nil
+}
+
+func (p *WorkStealingTaskPool) worker(id int) {
+	defer func() {
+		if atomic.AddInt32(&p.numGo, -1) == 0 {
+			p.mutex.Lock()
+			// 最后一个退出的工作协程负责完成 Shutdown
+			if p.state == stateClosing {
+				p.state = stateStopped
+				p.interruptCtxCancel()
+			}
+			p.mutex.Unlock()
+		}
+	}()
+	for {
+		if p.interruptCtx.Err() != nil {
+			return
+		}
+		task, ok := p.queues[id].popFront()
+		if !ok {
+			task, ok = p.steal(id)
+		}
+		if ok {
+			p.notFull.broadcast()
+			atomic.AddInt32(&p.numGoRunningTasks, 1)
+			_ = task.Run(p.interruptCtx)
+			atomic.AddInt32(&p.numGoRunningTasks, -1)
+			continue
+		}
+		select {
+		case <-p.notify:
+		case <-p.closing:
+			// Shutdown 之后不会再有新的任务，所有队列都为空时就可以退出了
+			if p.numOfWaitingTasks() == 0 {
+				return
+			}
+		case <-p.interruptCtx.Done():
+			return
+		}
+	}
+}
+
+// steal 从其它工作协程的队列尾部窃取一个任务
+func (p *WorkStealingTaskPool) steal(id int) (Task, bool) {
+	n := len(p.queues)
+	for i := 1; i < n; i++ {
+		if task, ok := p.queues[(id+i)%n].popBack(); ok {
+			return task, true
+		}
+	}
+	return nil, false
+}
+
+// Shutdown 将会拒绝提交新的任务，但是会继续执行已提交任务
+// 当执行完毕后，会往返回的 chan 中丢入信号
+func (p *WorkStealingTaskPool) Shutdown() (<-chan struct{}, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if err := p.checkRunning(); err != nil {
+		return nil, err
+	}
+	p.state = stateClosing
+	close(p.closing)
+	p.notFull.broadcast()
+	return p.interruptCtx.Done(), nil
+}
+
+// ShutdownNow 立刻关闭任务池，并且返回所有剩余未执行的任务（不包含正在执行的任务）
+// 与 OnDemandBlockTaskPool 一样，返回的任务中需要感知丢弃的任务会以 ErrTaskDiscarded 结束
+func (p *WorkStealingTaskPool) ShutdownNow() ([]Task, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if err := p.checkRunning(); err != nil {
+		return nil, err
+	}
+	p.state = stateStopped
+	p.interruptCtxCancel()
+	p.notFull.broadcast()
+	tasks := make([]Task, 0, p.numOfWaitingTasks())
+	for _, q := range p.queues {
+		for {
+			task, ok := q.popFront()
+			if !ok {
+				break
+			}
+			tasks = append(tasks, task)
+		}
+	}
+	notifyDiscarded(tasks...)
+	return tasks, nil
+}
+
+func (p *WorkStealingTaskPool) checkRunning() error {
+	switch p.state {
+	case stateCreated:
+		return fmt.Errorf("%w", errTaskPoolIsNotRunning)
+	case stateClosing:
+		return fmt.Errorf("%w", errTaskPoolIsClosing)
+	case stateStopped:
+		return fmt.Errorf("%w", errTaskPoolIsStopped)
+	}
+	return nil
+}
+
+func (p *WorkStealingTaskPool) numOfWaitingTasks() int {
+	n := 0
+	for _, q := range p.queues {
+		n += q.len()
+	}
+	return n
+}
+
+func (p *WorkStealingTaskPool) States(ctx context.Context, interval time.Duration) (<-chan State, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if p.interruptCtx.Err() != nil {
+		return nil, p.interruptCtx.Err()
+	}
+
+	statsChan := make(chan State)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case timeStamp := <-ticker.C:
+				p.sendState(statsChan, timeStamp.UnixNano())
+			case <-ctx.Done():
+				p.sendState(statsChan, time.Now().UnixNano())
+				close(statsChan)
+				return
+			case <-p.interruptCtx.Done():
+				p.sendState(statsChan, time.Now().UnixNano())
+				close(statsChan)
+				return
+			}
+		}
+	}()
+	return statsChan, nil
+}
+
+func (p *WorkStealingTaskPool) sendState(ch chan<- State, timeStamp int64) {
+	select {
+	case ch <- p.getState(timeStamp):
+	default:
+	}
+}
+
+func (p *WorkStealingTaskPool) getState(timeStamp int64) State {
+	p.mutex.RLock()
+	state := p.state
+	p.mutex.RUnlock()
+	return State{
+		PoolState:       state,
+		GoCnt:           atomic.LoadInt32(&p.numGo),
+		QueueSize:       cap(p.notify),
+		WaitingTasksCnt: p.numOfWaitingTasks(),
+		RunningTasksCnt: atomic.LoadInt32(&p.numGoRunningTasks),
+		Timestamp:       timeStamp,
+	}
+}
+
+// taskDeque 有界的双端队列
+// 所有者从队首取任务，窃取者从队尾取任务，尽量减少两者的冲突
+type taskDeque struct {
+	mutex sync.Mutex
+	buf   []Task
+	head  int
+	size  int
+}
+
+func newTaskDeque(capacity int) *taskDeque {
+	return &taskDeque{buf: make([]Task, capacity)}
+}
+
+func (d *taskDeque) pushBack(t Task) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.size == len(d.buf) {
+		return false
+	}
+	d.buf[(d.head+d.size)%len(d.buf)] = t
+	d.size++
+	return true
+}
+
+func (d *taskDeque) popFront() (Task, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.size == 0 {
+		return nil, false
+	}
+	t := d.buf[d.head]
+	d.buf[d.head] = nil
+	d.head = (d.head + 1) % len(d.buf)
+	d.size--
+	return t, true
+}
+
+func (d *taskDeque) popBack() (Task, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.size == 0 {
+		return nil, false
+	}
+	idx := (d.head + d.size - 1) % len(d.buf)
+	t := d.buf[idx]
+	d.buf[idx] = nil
+	d.size--
+	return t, true
+}
+
+func (d *taskDeque) len() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.size
+}
+
+// broadcaster 通过关闭 chan 的方式唤醒所有等待者
+// 等待者的数量是原子变量，没有等待者的时候 broadcast 不需要加锁，也不会创建 chan
+type broadcaster struct {
+	mutex   sync.Mutex
+	ch      chan struct{}
+	waiters int32
+}
+
+func newBroadcaster() *broadcaster {
+	return &broadcaster{ch: make(chan struct{})}
+}
+
+// wait 登记为等待者，并返回用于等待的 chan，等待结束之后需要调用 done
+func (b *broadcaster) wait() <-chan struct{} {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	atomic.AddInt32(&b.waiters, 1)
+	return b.ch
+}
+
+func (b *broadcaster) done() {
+	atomic.AddInt32(&b.waiters, -1)
+}
+
+func (b *broadcaster) broadcast() {
+	if atomic.LoadInt32(&b.waiters) == 0 {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	close(b.ch)
+	b.ch = make(chan struct{})
+}
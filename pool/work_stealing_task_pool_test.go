@@ -0,0 +1,325 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWorkStealingTaskPool(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		workers   int
+		queueSize int
+		wantErr   error
+	}{
+		{
+			name:      "workers小于1",
+			workers:   0,
+			queueSize: 1,
+			wantErr:   errInvalidArgument,
+		},
+		{
+			name:      "queueSize小于1",
+			workers:   1,
+			queueSize: 0,
+			wantErr:   errInvalidArgument,
+		},
+		{
+			name:      "正常创建",
+			workers:   2,
+			queueSize: 3,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := NewWorkStealingTaskPool(tc.workers, tc.queueSize)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, stateCreated, p.state)
+			assert.Equal(t, tc.workers, len(p.queues))
+			assert.Equal(t, tc.workers*tc.queueSize, cap(p.notify))
+		})
+	}
+}
+
+func TestWorkStealingTaskPool_Submit(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewWorkStealingTaskPool(4, 16)
+	require.NoError(t, err)
+	assert.ErrorIs(t, p.Submit(context.Background(), nil), errTaskIsInvalid)
+
+	const n = 1000
+	var cnt int32
+	var wg sync.WaitGroup
+	wg.Add(n)
+	// Start 之前提交的任务在 Start 之后执行
+	for i := 0; i < 10; i++ {
+		require.NoError(t, p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error {
+			defer wg.Done()
+			atomic.AddInt32(&cnt, 1)
+			return nil
+		})))
+	}
+	require.NoError(t, p.Start())
+	assert.ErrorIs(t, p.Start(), errTaskPoolIsStarted)
+	for i := 10; i < n; i++ {
+		i := i
+		require.NoError(t, p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error {
+			defer wg.Done()
+			atomic.AddInt32(&cnt, 1)
+			if i%100 == 0 {
+				panic("mock panic")
+			}
+			return nil
+		})))
+	}
+	wg.Wait()
+	assert.Equal(t, int32(n), atomic.LoadInt32(&cnt))
+	// panic 不会导致工作协程退出
+	assert.Equal(t, int32(4), atomic.LoadInt32(&p.numGo))
+}
+
+func TestWorkStealingTaskPool_SubmitBlocked(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewWorkStealingTaskPool(1, 1)
+	require.NoError(t, err)
+	require.NoError(t, p.Start())
+
+	wait := make(chan struct{})
+	running := make(chan struct{})
+	require.NoError(t, p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error {
+		close(running)
+		<-wait
+		return nil
+	})))
+	<-running
+	require.NoError(t, p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error { return nil })))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	err = p.Submit(ctx, TaskFunc(func(ctx context.Context) error { return nil }))
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// 队列有空位之后被阻塞的 Submit 会被唤醒
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error { return nil })))
+	}()
+	time.Sleep(time.Millisecond)
+	close(wait)
+	<-done
+}
+
+func TestBroadcaster(t *testing.T) {
+	b := newBroadcaster()
+	ch := b.ch
+	// 没有等待者的时候不会关闭 chan
+	b.broadcast()
+	assert.Equal(t, ch, b.ch)
+
+	signal := b.wait()
+	b.broadcast()
+	<-signal
+	b.done()
+	assert.NotEqual(t, ch, b.ch)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&b.waiters))
+}
+
+func TestWorkStealingTaskPool_Steal(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewWorkStealingTaskPool(2, 10)
+	require.NoError(t, err)
+
+	// 所有任务都放入第一个工作协程的队列，第二个工作协程只能靠窃取执行任务
+	const n = 10
+	var wg sync.WaitGroup
+	wg.Add(n)
+	wait := make(chan struct{})
+	var cnt int32
+	for i := 0; i < n; i++ {
+		require.True(t, p.queues[0].pushBack(&taskWrapper{t: TaskFunc(func(ctx context.Context) error {
+			defer wg.Done()
+			if atomic.AddInt32(&cnt, 1) == 1 {
+				<-wait
+			}
+			return nil
+		})}))
+		p.notify <- struct{}{}
+	}
+	require.NoError(t, p.Start())
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&cnt) == n
+	}, time.Second, time.Millisecond)
+	close(wait)
+	wg.Wait()
+}
+
+func TestWorkStealingTaskPool_Shutdown(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewWorkStealingTaskPool(2, 10)
+	require.NoError(t, err)
+	_, err = p.Shutdown()
+	assert.ErrorIs(t, err, errTaskPoolIsNotRunning)
+	_, err = p.ShutdownNow()
+	assert.ErrorIs(t, err, errTaskPoolIsNotRunning)
+
+	require.NoError(t, p.Start())
+	const n = 20
+	var cnt int32
+	for i := 0; i < n; i++ {
+		require.NoError(t, p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error {
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&cnt, 1)
+			return nil
+		})))
+	}
+	done, err := p.Shutdown()
+	require.NoError(t, err)
+
+	err = p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error { return nil }))
+	assert.ErrorIs(t, err, errTaskPoolIsClosing)
+	_, err = p.Shutdown()
+	assert.ErrorIs(t, err, errTaskPoolIsClosing)
+	_, err = p.ShutdownNow()
+	assert.ErrorIs(t, err, errTaskPoolIsClosing)
+
+	<-done
+	// 已经提交的任务都会被执行
+	assert.Equal(t, int32(n), atomic.LoadInt32(&cnt))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&p.numGo))
+	assert.Equal(t, stateStopped, p.getState(0).PoolState)
+
+	err = p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error { return nil }))
+	assert.ErrorIs(t, err, errTaskPoolIsStopped)
+	assert.ErrorIs(t, p.Start(), errTaskPoolIsStopped)
+	_, err = p.Shutdown()
+	assert.ErrorIs(t, err, errTaskPoolIsStopped)
+}
+
+func TestWorkStealingTaskPool_ShutdownNow(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewWorkStealingTaskPool(1, 10)
+	require.NoError(t, err)
+	require.NoError(t, p.Start())
+
+	running := make(chan struct{})
+	require.NoError(t, p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error {
+		close(running)
+		// 正在执行的任务通过 ctx 感知 ShutdownNow
+		<-ctx.Done()
+		return ctx.Err()
+	})))
+	<-running
+	const n = 5
+	for i := 0; i < n; i++ {
+		require.NoError(t, p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error { return nil })))
+	}
+
+	tasks, err := p.ShutdownNow()
+	require.NoError(t, err)
+	assert.Equal(t, n, len(tasks))
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&p.numGo) == 0
+	}, time.Second, time.Millisecond)
+
+	_, err = p.ShutdownNow()
+	assert.ErrorIs(t, err, errTaskPoolIsStopped)
+	err = p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error { return nil }))
+	assert.ErrorIs(t, err, errTaskPoolIsStopped)
+}
+
+func TestWorkStealingTaskPool_States(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewWorkStealingTaskPool(2, 5)
+	require.NoError(t, err)
+	require.NoError(t, p.Submit(context.Background(), TaskFunc(func(ctx context.Context) error { return nil })))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = p.States(ctx, time.Millisecond)
+	assert.Equal(t, context.Canceled, err)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	ch, err := p.States(ctx, time.Millisecond)
+	require.NoError(t, err)
+	s := <-ch
+	assert.Equal(t, stateCreated, s.PoolState)
+	assert.Equal(t, 10, s.QueueSize)
+	assert.Equal(t, 1, s.WaitingTasksCnt)
+	cancel()
+	for range ch {
+	}
+
+	require.NoError(t, p.Start())
+	_, err = p.ShutdownNow()
+	require.NoError(t, err)
+	_, err = p.States(context.Background(), time.Millisecond)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func BenchmarkTaskPool_ShortTasks(b *testing.B) {
+	workers := runtime.GOMAXPROCS(0)
+	const queueSize = 1024
+	task := TaskFunc(func(ctx context.Context) error { return nil })
+
+	b.Run("OnDemandBlockTaskPool", func(b *testing.B) {
+		p, err := NewOnDemandBlockTaskPool(workers, queueSize)
+		require.NoError(b, err)
+		require.NoError(b, p.Start())
+		benchmarkTaskPoolSubmit(b, p, task)
+	})
+
+	b.Run("WorkStealingTaskPool", func(b *testing.B) {
+		p, err := NewWorkStealingTaskPool(workers, queueSize/workers+1)
+		require.NoError(b, err)
+		require.NoError(b, p.Start())
+		benchmarkTaskPoolSubmit(b, p, task)
+	})
+}
+
+func benchmarkTaskPoolSubmit(b *testing.B, p TaskPool, task Task) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = p.Submit(context.Background(), task)
+		}
+	})
+	b.StopTimer()
+	done, err := p.Shutdown()
+	require.NoError(b, err)
+	<-done
+}
@@ -57,6 +57,21 @@ func (c *ConcurrentPriorityQueue[T]) Dequeue() (T, error) {
 	return c.pq.Dequeue()
 }
 
+// PeekLast 返回最后一个出队的元素，也就是按照 compare 排序最大的元素
+func (c *ConcurrentPriorityQueue[T]) PeekLast() (T, error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	return c.pq.PeekLast()
+}
+
+// DequeueLast 移除并返回最后一个出队的元素，也就是按照 compare 排序最大的元素
+// 可以用于队列满的时候淘汰优先级最低的元素
+func (c *ConcurrentPriorityQueue[T]) DequeueLast() (T, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.pq.DequeueLast()
+}
+
 // NewConcurrentPriorityQueue 创建优先队列 capacity <= 0 时，为无界队列
 func NewConcurrentPriorityQueue[T any](capacity int, compare ekit.Comparator[T]) *ConcurrentPriorityQueue[T] {
 	return &ConcurrentPriorityQueue[T]{
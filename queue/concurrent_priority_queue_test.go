@@ -235,6 +235,28 @@ func TestConcurrentPriorityQueue_Dequeue(t *testing.T) {
 
 // 测试同时并发出入队。只要并发安全，并发出入队后的剩余元素数量+报错数量应该符合预期
 // TODO 有待设计更好的并发出入队测试方案
+func TestConcurrentPriorityQueue_DequeueLast(t *testing.T) {
+	q := NewConcurrentPriorityQueue[int](0, ekit.ComparatorRealNumber[int])
+	_, err := q.DequeueLast()
+	assert.Equal(t, errEmptyQueue, err)
+	for _, val := range []int{3, 1, 5, 2, 4} {
+		require.NoError(t, q.Enqueue(val))
+	}
+	val, err := q.PeekLast()
+	require.NoError(t, err)
+	assert.Equal(t, 5, val)
+	val, err = q.DequeueLast()
+	require.NoError(t, err)
+	assert.Equal(t, 5, val)
+	val, err = q.DequeueLast()
+	require.NoError(t, err)
+	assert.Equal(t, 4, val)
+	val, err = q.Dequeue()
+	require.NoError(t, err)
+	assert.Equal(t, 1, val)
+	assert.Equal(t, 2, q.Len())
+}
+
 func TestConcurrentPriorityQueue_EnqueueDequeue(t *testing.T) {
 	testCases := []struct {
 		name    string
@@ -20,13 +20,16 @@ import (
 	"time"
 )
 
-var _ Strategy = (*AdaptiveTimeoutRetryStrategy)(nil)
+var (
+	_ Strategy          = (*AdaptiveTimeoutRetryStrategy)(nil)
+	_ CloneableStrategy = (*CloneableAdaptiveTimeoutRetryStrategy)(nil)
+)
 
 type AdaptiveTimeoutRetryStrategy struct {
 	strategy   Strategy // 基础重试策略
 	threshold  int      // 超时比率阈值 (单位：比特数量)
 	ringBuffer []uint64 // 比特环（滑动窗口存储超时信息）
-	reqCount   uint64   // 请求数量
+	reqCount   *uint64  // 请求数量，和 ringBuffer 一起在 Clone 得到的 Strategy 之间共享
 	bufferLen  int      // 滑动窗口长度
 	bitCnt     uint64   // 比特位总数
 }
@@ -49,7 +52,7 @@ func (s *AdaptiveTimeoutRetryStrategy) Report(err error) Strategy {
 }
 
 func (s *AdaptiveTimeoutRetryStrategy) markSuccess() {
-	count := atomic.AddUint64(&s.reqCount, 1)
+	count := atomic.AddUint64(s.reqCount, 1)
 	count = count % s.bitCnt
 	// 对2^x进行取模或者整除运算时可以用位运算代替除法和取模
 	// count / 64 可以转换成 count >> 6。 位运算会更高效。
@@ -61,7 +64,7 @@ func (s *AdaptiveTimeoutRetryStrategy) markSuccess() {
 }
 
 func (s *AdaptiveTimeoutRetryStrategy) markFail() {
-	count := atomic.AddUint64(&s.reqCount, 1)
+	count := atomic.AddUint64(s.reqCount, 1)
 	count = count % s.bitCnt
 	idx := count >> 6
 	bitPos := count & 63
@@ -86,5 +89,43 @@ func NewAdaptiveTimeoutRetryStrategy(strategy Strategy, bufferLen, threshold int
 		bufferLen:  bufferLen,
 		ringBuffer: make([]uint64, bufferLen),
 		bitCnt:     uint64(64) * uint64(bufferLen),
+		reqCount:   new(uint64),
+	}
+}
+
+// CloneableAdaptiveTimeoutRetryStrategy 可以复用的 AdaptiveTimeoutRetryStrategy，被包装的 Strategy 必须是 CloneableStrategy
+// Clone 得到的 Strategy 共享同一个滑动窗口，从而根据所有调用的结果判断是否重试
+type CloneableAdaptiveTimeoutRetryStrategy struct {
+	*AdaptiveTimeoutRetryStrategy
+	strategy CloneableStrategy
+}
+
+func NewCloneableAdaptiveTimeoutRetryStrategy(strategy CloneableStrategy, bufferLen, threshold int) *CloneableAdaptiveTimeoutRetryStrategy {
+	return &CloneableAdaptiveTimeoutRetryStrategy{
+		AdaptiveTimeoutRetryStrategy: NewAdaptiveTimeoutRetryStrategy(strategy, bufferLen, threshold),
+		strategy:                     strategy,
+	}
+}
+
+func (s *CloneableAdaptiveTimeoutRetryStrategy) Report(err error) Strategy {
+	s.AdaptiveTimeoutRetryStrategy.Report(err)
+	return s
+}
+
+// Clone 克隆被包装的 Strategy，并且共享滑动窗口
+// 如果被包装的 Strategy 的 Clone 结果不再是 CloneableStrategy，那么返回 AdaptiveTimeoutRetryStrategy
+func (s *CloneableAdaptiveTimeoutRetryStrategy) Clone() Strategy {
+	inner := s.strategy.Clone()
+	res := *s.AdaptiveTimeoutRetryStrategy
+	res.strategy = inner
+	if cs, ok := inner.(CloneableStrategy); ok {
+		return &CloneableAdaptiveTimeoutRetryStrategy{AdaptiveTimeoutRetryStrategy: &res, strategy: cs}
 	}
+	return &res
+}
+
+// Reset 重置被包装的 Strategy，滑动窗口记录的是所有调用的结果，所以不会被重置
+func (s *CloneableAdaptiveTimeoutRetryStrategy) Reset() {
+	s.strategy.Reset()
+	s.AdaptiveTimeoutRetryStrategy.strategy = s.strategy
 }
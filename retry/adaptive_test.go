@@ -15,11 +15,13 @@
 package retry
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewAdaptiveTimeoutRetryStrategy_New(t *testing.T) {
@@ -79,6 +81,31 @@ func TestAdaptiveTimeoutRetryStrategy_Next(t *testing.T) {
 	}
 }
 
+func TestCloneableAdaptiveTimeoutRetryStrategy_Clone(t *testing.T) {
+	base, err := NewFixedIntervalRetryStrategy(time.Second, 2)
+	require.NoError(t, err)
+	s := NewCloneableAdaptiveTimeoutRetryStrategy(base, 1, 2)
+	testCloneableStrategy(t, s, []time.Duration{time.Second, time.Second})
+	assert.Equal(t, s, s.Report(nil))
+
+	// Clone 得到的 Strategy 共享滑动窗口
+	c1 := s.Clone()
+	c2 := s.Clone()
+	c1.Report(errors.New("timeout"))
+	c2.Report(errors.New("timeout"))
+	_, ok := s.Next()
+	assert.False(t, ok)
+	_, ok = c1.Next()
+	assert.False(t, ok)
+
+	// 被包装的 Strategy 的 Clone 结果不是 CloneableStrategy
+	clone := NewCloneableAdaptiveTimeoutRetryStrategy(&nonCloneableClone{}, 1, 2).Clone()
+	_, ok = clone.(CloneableStrategy)
+	assert.False(t, ok)
+	_, ok = clone.(*AdaptiveTimeoutRetryStrategy)
+	assert.True(t, ok)
+}
+
 func ExampleAdaptiveTimeoutRetryStrategy_Next() {
 	baseStrategy, err := NewExponentialBackoffRetryStrategy(time.Second, time.Second*5, 10)
 	if err != nil {
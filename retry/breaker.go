@@ -0,0 +1,304 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ecodeclub/ekit/bean/option"
+	"github.com/ecodeclub/ekit/internal/errs"
+)
+
+// ErrCircuitOpen 熔断器处于打开状态，或者处于半开状态但是探测请求已经用完
+var ErrCircuitOpen = errors.New("ekit: 熔断器已打开")
+
+// CircuitState 熔断器的状态
+type CircuitState int32
+
+const (
+	// CircuitClosed 关闭状态，所有请求都会被放行
+	CircuitClosed CircuitState = iota
+	// CircuitOpen 打开状态，所有请求都会被拒绝
+	CircuitOpen
+	// CircuitHalfOpen 半开状态，只放行有限的探测请求
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("unknown(%d)", int32(s))
+	}
+}
+
+// CircuitBreaker 熔断器
+// 关闭状态下，最近 windowSize 个请求中的失败率达到 failureRateThreshold，并且请求数达到 minRequests 时打开；
+// 打开 openTimeout 之后进入半开状态，放行 halfOpenProbes 个探测请求，
+// 全部成功则关闭，任何一个失败则重新打开
+// CircuitBreaker 是并发安全的
+type CircuitBreaker struct {
+	windowSize           int
+	failureRateThreshold float64
+	minRequests          int
+	// minRequests 是否由用户设置，没有设置时默认值不超过 windowSize
+	minRequestsSet bool
+	openTimeout    time.Duration
+	halfOpenProbes int
+	onStateChange  func(from, to CircuitState)
+
+	mutex sync.Mutex
+	state CircuitState
+	// 每次状态变化都会递增，用于忽略状态变化之前放行的请求的结果
+	generation uint64
+	// 滑动窗口，true 代表失败
+	ring     []bool
+	pos      int
+	total    int
+	failures int
+	openedAt time.Time
+	// 半开状态下已经放行的探测请求数和成功数
+	probes         int
+	probeSuccesses int
+
+	now func() time.Time
+}
+
+// NewCircuitBreaker 创建一个 CircuitBreaker
+// 默认最近 100 个请求中失败率达到 50%，并且至少有 20 个请求时打开，打开 30 秒之后放行 1 个探测请求
+// 没有设置 minRequests 时，默认值不超过 windowSize；设置了的话，minRequests 不能大于 windowSize，否则熔断器永远不会打开
+func NewCircuitBreaker(opts ...option.Option[CircuitBreaker]) (*CircuitBreaker, error) {
+	b := &CircuitBreaker{
+		windowSize:           100,
+		failureRateThreshold: 0.5,
+		minRequests:          20,
+		openTimeout:          30 * time.Second,
+		halfOpenProbes:       1,
+		now:                  time.Now,
+	}
+	option.Apply(b, opts...)
+	if !b.minRequestsSet && b.minRequests > b.windowSize {
+		b.minRequests = b.windowSize
+	}
+	if b.windowSize < 1 || b.minRequests < 1 || b.halfOpenProbes < 1 {
+		return nil, errs.NewErrInvalidBreakerSize(b.windowSize, b.minRequests, b.halfOpenProbes)
+	}
+	if b.minRequests > b.windowSize {
+		return nil, errs.NewErrInvalidBreakerMinRequests(b.minRequests, b.windowSize)
+	}
+	if b.failureRateThreshold <= 0 || b.failureRateThreshold > 1 {
+		return nil, errs.NewErrInvalidBreakerFailureRate(b.failureRateThreshold)
+	}
+	if b.openTimeout <= 0 {
+		return nil, errs.NewErrInvalidBreakerOpenTimeout(b.openTimeout)
+	}
+	b.ring = make([]bool, b.windowSize)
+	return b, nil
+}
+
+// WithBreakerWindowSize 设置滑动窗口的大小，即统计最近多少个请求的失败率
+func WithBreakerWindowSize(n int) option.Option[CircuitBreaker] {
+	return func(b *CircuitBreaker) {
+		b.windowSize = n
+	}
+}
+
+// WithBreakerFailureRateThreshold 设置打开熔断器的失败率阈值，取值范围 (0, 1]
+func WithBreakerFailureRateThreshold(rate float64) option.Option[CircuitBreaker] {
+	return func(b *CircuitBreaker) {
+		b.failureRateThreshold = rate
+	}
+}
+
+// WithBreakerMinRequests 设置打开熔断器所需的最少请求数，避免请求量很小的时候误判
+func WithBreakerMinRequests(n int) option.Option[CircuitBreaker] {
+	return func(b *CircuitBreaker) {
+		b.minRequests = n
+		b.minRequestsSet = true
+	}
+}
+
+// WithBreakerOpenTimeout 设置熔断器打开多久之后进入半开状态
+func WithBreakerOpenTimeout(d time.Duration) option.Option[CircuitBreaker] {
+	return func(b *CircuitBreaker) {
+		b.openTimeout = d
+	}
+}
+
+// WithBreakerHalfOpenProbes 设置半开状态下放行的探测请求数
+func WithBreakerHalfOpenProbes(n int) option.Option[CircuitBreaker] {
+	return func(b *CircuitBreaker) {
+		b.halfOpenProbes = n
+	}
+}
+
+// WithBreakerStateChange 设置状态变化的回调，回调不会在持有锁的时候被调用
+func WithBreakerStateChange(fn func(from, to CircuitState)) option.Option[CircuitBreaker] {
+	return func(b *CircuitBreaker) {
+		b.onStateChange = fn
+	}
+}
+
+// State 返回熔断器当前的状态
+func (b *CircuitBreaker) State() CircuitState {
+	b.mutex.Lock()
+	from := b.state
+	changed := b.checkOpenTimeout()
+	state := b.state
+	b.mutex.Unlock()
+	if changed {
+		b.notify(from, state)
+	}
+	return state
+}
+
+// Allow 判断是否放行一个请求
+// 放行的话返回 done，调用者必须在请求结束之后调用 done 报告请求的结果，err 为 nil 代表成功
+// 否则返回 ErrCircuitOpen
+func (b *CircuitBreaker) Allow() (done func(err error), err error) {
+	b.mutex.Lock()
+	from := b.state
+	changed := b.checkOpenTimeout()
+	to := b.state
+	switch b.state {
+	case CircuitOpen:
+		err = fmt.Errorf("%w", ErrCircuitOpen)
+	case CircuitHalfOpen:
+		if b.probes >= b.halfOpenProbes {
+			err = fmt.Errorf("%w", ErrCircuitOpen)
+		} else {
+			b.probes++
+		}
+	}
+	generation := b.generation
+	b.mutex.Unlock()
+	if changed {
+		b.notify(from, to)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func(err error) {
+		b.record(generation, err)
+	}, nil
+}
+
+// Execute 在熔断器放行的情况下执行 fn，并记录 fn 的结果
+// 熔断器不放行时返回 ErrCircuitOpen
+func (b *CircuitBreaker) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	defer reportPanic(done)
+	err = fn(ctx)
+	done(err)
+	return err
+}
+
+// reportPanic 将业务的 panic 作为失败报告给熔断器，然后继续 panic
+// 否则半开状态下 panic 的探测请求永远不会报告结果，熔断器会一直停留在半开状态
+// 必须通过 defer 直接调用
+func reportPanic(done func(err error)) {
+	if r := recover(); r != nil {
+		done(fmt.Errorf("ekit: 业务 panic %v", r))
+		panic(r)
+	}
+}
+
+func (b *CircuitBreaker) record(generation uint64, err error) {
+	b.mutex.Lock()
+	if generation != b.generation {
+		// 状态已经变化，结果已经没有意义
+		b.mutex.Unlock()
+		return
+	}
+	from := b.state
+	switch b.state {
+	case CircuitClosed:
+		b.add(err != nil)
+		if b.total >= b.minRequests && float64(b.failures) >= float64(b.total)*b.failureRateThreshold {
+			b.setState(CircuitOpen)
+		}
+	case CircuitHalfOpen:
+		if err != nil {
+			b.setState(CircuitOpen)
+		} else {
+			b.probeSuccesses++
+			if b.probeSuccesses >= b.halfOpenProbes {
+				b.setState(CircuitClosed)
+			}
+		}
+	}
+	to := b.state
+	b.mutex.Unlock()
+	if from != to {
+		b.notify(from, to)
+	}
+}
+
+// add 将一个请求的结果放入滑动窗口，必须在持有锁的时候调用
+func (b *CircuitBreaker) add(failed bool) {
+	if b.total == len(b.ring) {
+		// 窗口已满，淘汰最老的结果
+		if b.ring[b.pos] {
+			b.failures--
+		}
+	} else {
+		b.total++
+	}
+	b.ring[b.pos] = failed
+	if failed {
+		b.failures++
+	}
+	b.pos = (b.pos + 1) % len(b.ring)
+}
+
+// checkOpenTimeout 如果打开已经超过 openTimeout，那么进入半开状态，必须在持有锁的时候调用
+func (b *CircuitBreaker) checkOpenTimeout() bool {
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.openTimeout {
+		b.setState(CircuitHalfOpen)
+		return true
+	}
+	return false
+}
+
+// setState 迁移状态并重置统计信息，必须在持有锁的时候调用
+func (b *CircuitBreaker) setState(state CircuitState) {
+	b.state = state
+	b.generation++
+	b.probes, b.probeSuccesses = 0, 0
+	switch state {
+	case CircuitOpen:
+		b.openedAt = b.now()
+	case CircuitClosed:
+		b.pos, b.total, b.failures = 0, 0, 0
+	}
+}
+
+func (b *CircuitBreaker) notify(from, to CircuitState) {
+	if b.onStateChange != nil {
+		b.onStateChange(from, to)
+	}
+}
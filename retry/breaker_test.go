@@ -0,0 +1,240 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ekit/bean/option"
+	"github.com/ecodeclub/ekit/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCircuitBreaker(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opts    []option.Option[CircuitBreaker]
+		wantErr error
+
+		wantMinRequests int
+	}{
+		{
+			name: "默认配置",
+		},
+		{
+			name:    "windowSize等于0",
+			opts:    []option.Option[CircuitBreaker]{WithBreakerWindowSize(0)},
+			wantErr: errs.NewErrInvalidBreakerSize(0, 0, 1),
+		},
+		{
+			name:    "halfOpenProbes等于0",
+			opts:    []option.Option[CircuitBreaker]{WithBreakerHalfOpenProbes(0)},
+			wantErr: errs.NewErrInvalidBreakerSize(100, 20, 0),
+		},
+		{
+			name:    "失败率阈值大于1",
+			opts:    []option.Option[CircuitBreaker]{WithBreakerFailureRateThreshold(1.5)},
+			wantErr: errs.NewErrInvalidBreakerFailureRate(1.5),
+		},
+		{
+			name:    "openTimeout等于0",
+			opts:    []option.Option[CircuitBreaker]{WithBreakerOpenTimeout(0)},
+			wantErr: errs.NewErrInvalidBreakerOpenTimeout(0),
+		},
+		{
+			name:    "minRequests大于windowSize",
+			opts:    []option.Option[CircuitBreaker]{WithBreakerWindowSize(10), WithBreakerMinRequests(20)},
+			wantErr: errs.NewErrInvalidBreakerMinRequests(20, 10),
+		},
+		{
+			name:            "没有设置minRequests时默认值不超过windowSize",
+			opts:            []option.Option[CircuitBreaker]{WithBreakerWindowSize(10)},
+			wantMinRequests: 10,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := NewCircuitBreaker(tc.opts...)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, CircuitClosed, b.State())
+			if tc.wantMinRequests > 0 {
+				assert.Equal(t, tc.wantMinRequests, b.minRequests)
+			}
+		})
+	}
+}
+
+type stateChange struct {
+	from, to CircuitState
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var changes []stateChange
+	b, err := NewCircuitBreaker(
+		WithBreakerWindowSize(4),
+		WithBreakerMinRequests(3),
+		WithBreakerFailureRateThreshold(0.5),
+		WithBreakerOpenTimeout(time.Second),
+		WithBreakerHalfOpenProbes(2),
+		WithBreakerStateChange(func(from, to CircuitState) {
+			changes = append(changes, stateChange{from: from, to: to})
+		}))
+	require.NoError(t, err)
+	now := time.Unix(1000, 0)
+	b.now = func() time.Time { return now }
+
+	bizErr := errors.New("biz error")
+	succeed := func(ctx context.Context) error { return nil }
+	fail := func(ctx context.Context) error { return bizErr }
+	ctx := context.Background()
+
+	// 请求数没有达到 minRequests，不会打开
+	assert.Equal(t, bizErr, b.Execute(ctx, fail))
+	assert.Equal(t, bizErr, b.Execute(ctx, fail))
+	assert.Equal(t, CircuitClosed, b.State())
+	require.NoError(t, b.Execute(ctx, succeed))
+	assert.Equal(t, CircuitOpen, b.State())
+
+	// 滑动窗口中旧的结果会被淘汰
+	now = now.Add(time.Second)
+	require.NoError(t, b.Execute(ctx, succeed))
+	require.NoError(t, b.Execute(ctx, succeed))
+	assert.Equal(t, CircuitClosed, b.State())
+	for i := 0; i < 4; i++ {
+		require.NoError(t, b.Execute(ctx, succeed))
+	}
+	assert.Equal(t, bizErr, b.Execute(ctx, fail))
+	// 窗口中 [succeed, succeed, succeed, fail]
+	assert.Equal(t, CircuitClosed, b.State())
+	assert.Equal(t, bizErr, b.Execute(ctx, fail))
+	// 窗口中 [succeed, succeed, fail, fail]
+	assert.Equal(t, CircuitOpen, b.State())
+	assert.ErrorIs(t, b.Execute(ctx, succeed), ErrCircuitOpen)
+
+	// 半开状态下最多放行 2 个探测请求，有一个失败就重新打开
+	now = now.Add(time.Second)
+	done1, err := b.Allow()
+	require.NoError(t, err)
+	assert.Equal(t, CircuitHalfOpen, b.State())
+	done2, err := b.Allow()
+	require.NoError(t, err)
+	_, err = b.Allow()
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	done1(nil)
+	done2(bizErr)
+	assert.Equal(t, CircuitOpen, b.State())
+
+	// 探测请求全部成功则关闭
+	now = now.Add(time.Second)
+	require.NoError(t, b.Execute(ctx, succeed))
+	require.NoError(t, b.Execute(ctx, succeed))
+	assert.Equal(t, CircuitClosed, b.State())
+
+	// 状态变化之前放行的请求，其结果会被忽略
+	done, err := b.Allow()
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, bizErr, b.Execute(ctx, fail))
+	}
+	assert.Equal(t, CircuitOpen, b.State())
+	now = now.Add(time.Second)
+	done(nil)
+	assert.Equal(t, CircuitHalfOpen, b.State())
+
+	assert.Equal(t, []stateChange{
+		{from: CircuitClosed, to: CircuitOpen},
+		{from: CircuitOpen, to: CircuitHalfOpen},
+		{from: CircuitHalfOpen, to: CircuitClosed},
+		{from: CircuitClosed, to: CircuitOpen},
+		{from: CircuitOpen, to: CircuitHalfOpen},
+		{from: CircuitHalfOpen, to: CircuitOpen},
+		{from: CircuitOpen, to: CircuitHalfOpen},
+		{from: CircuitHalfOpen, to: CircuitClosed},
+		{from: CircuitClosed, to: CircuitOpen},
+		{from: CircuitOpen, to: CircuitHalfOpen},
+	}, changes)
+}
+
+func TestCircuitBreaker_Panic(t *testing.T) {
+	b, err := NewCircuitBreaker(WithBreakerMinRequests(1), WithBreakerOpenTimeout(time.Second))
+	require.NoError(t, err)
+	now := time.Unix(1000, 0)
+	b.now = func() time.Time { return now }
+	ctx := context.Background()
+	panicFn := func(ctx context.Context) error {
+		panic("mock panic")
+	}
+
+	// panic 被记为失败
+	assert.Panics(t, func() {
+		_ = b.Execute(ctx, panicFn)
+	})
+	assert.Equal(t, CircuitOpen, b.State())
+
+	// 半开状态下 panic 的探测请求会重新打开熔断器，而不是一直停留在半开状态
+	now = now.Add(time.Second)
+	assert.Panics(t, func() {
+		_ = RetryWithContext(ctx, MockStrategy{}, panicFn, WithCircuitBreaker(b))
+	})
+	assert.Equal(t, CircuitOpen, b.State())
+	now = now.Add(time.Second)
+	assert.Equal(t, CircuitHalfOpen, b.State())
+	require.NoError(t, b.Execute(ctx, func(ctx context.Context) error { return nil }))
+	assert.Equal(t, CircuitClosed, b.State())
+}
+
+func TestCircuitState_String(t *testing.T) {
+	assert.Equal(t, "closed", CircuitClosed.String())
+	assert.Equal(t, "open", CircuitOpen.String())
+	assert.Equal(t, "half-open", CircuitHalfOpen.String())
+	assert.Equal(t, "unknown(10)", CircuitState(10).String())
+}
+
+func TestRetryWithContext_CircuitBreaker(t *testing.T) {
+	b, err := NewCircuitBreaker(
+		WithBreakerWindowSize(10),
+		WithBreakerMinRequests(2),
+		WithBreakerFailureRateThreshold(1))
+	require.NoError(t, err)
+	s, err := NewFixedIntervalRetryStrategy(time.Millisecond, 10)
+	require.NoError(t, err)
+
+	calls := 0
+	bizErr := errors.New("biz error")
+	err = RetryWithContext(context.Background(), s, func(ctx context.Context) error {
+		calls++
+		return bizErr
+	}, WithCircuitBreaker(b))
+	// 连续失败两次之后熔断器打开，不再重试
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 2, calls)
+
+	// Permanent 标记的 error 不会被记为失败
+	b, err = NewCircuitBreaker(WithBreakerMinRequests(1), WithBreakerFailureRateThreshold(1))
+	require.NoError(t, err)
+	err = RetryWithContext(context.Background(), s, func(ctx context.Context) error {
+		return Permanent(bizErr)
+	}, WithCircuitBreaker(b))
+	assert.Equal(t, bizErr, err)
+	assert.Equal(t, CircuitClosed, b.State())
+}
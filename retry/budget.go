@@ -0,0 +1,190 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ecodeclub/ekit/internal/errs"
+)
+
+// 滑动窗口被划分成多少个桶
+const budgetBuckets = 10
+
+// RetryBudget 重试预算，用于限制一段时间内重试占请求的比例，避免依赖故障时产生重试风暴
+// 与单次调用的最大重试次数不同，RetryBudget 应该被同一个客户端的所有调用共享
+// 在滑动窗口 window 内，重试次数最多为 请求数 * ratio + minRetriesPerSecond * window 秒数
+// RetryBudget 是并发安全的
+type RetryBudget struct {
+	ratio float64
+	// 窗口内至少允许的重试次数，保证请求量很小的时候也能重试
+	minRetries float64
+	bucketSize time.Duration
+
+	mutex   sync.Mutex
+	buckets [budgetBuckets]budgetBucket
+
+	now func() time.Time
+}
+
+type budgetBucket struct {
+	// 桶对应的时间段，即 时间戳 / bucketSize
+	epoch    int64
+	requests int64
+	retries  int64
+}
+
+// NewRetryBudget 创建一个 RetryBudget
+// ratio 是窗口内重试次数占请求数的最大比例，例如 0.1 表示重试最多占请求的 10%
+// minRetriesPerSecond 是每秒至少允许的重试次数
+// window 是滑动窗口的大小
+func NewRetryBudget(ratio float64, minRetriesPerSecond int, window time.Duration) (*RetryBudget, error) {
+	if ratio < 0 {
+		return nil, errs.NewErrInvalidRetryBudgetRatio(ratio)
+	}
+	if window <= 0 {
+		return nil, errs.NewErrInvalidRetryBudgetWindow(window)
+	}
+	bucketSize := window / budgetBuckets
+	if bucketSize == 0 {
+		bucketSize = 1
+	}
+	return &RetryBudget{
+		ratio:      ratio,
+		minRetries: float64(minRetriesPerSecond) * window.Seconds(),
+		bucketSize: bucketSize,
+		now:        time.Now,
+	}, nil
+}
+
+// recordRequest 记录一次请求
+func (b *RetryBudget) recordRequest() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.current().requests++
+}
+
+// tryRetry 如果预算充足，那么记录一次重试并返回 true
+func (b *RetryBudget) tryRetry() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	cur := b.current()
+	var requests, retries int64
+	for _, bucket := range b.buckets {
+		if cur.epoch-bucket.epoch < budgetBuckets {
+			requests += bucket.requests
+			retries += bucket.retries
+		}
+	}
+	if float64(retries+1) > float64(requests)*b.ratio+b.minRetries {
+		return false
+	}
+	cur.retries++
+	return true
+}
+
+// current 返回当前时间对应的桶，过期的桶会被重置，必须在持有锁的时候调用
+func (b *RetryBudget) current() *budgetBucket {
+	epoch := b.now().UnixNano() / int64(b.bucketSize)
+	bucket := &b.buckets[epoch%budgetBuckets]
+	if bucket.epoch != epoch {
+		*bucket = budgetBucket{epoch: epoch}
+	}
+	return bucket
+}
+
+var (
+	_ Strategy          = (*BudgetRetryStrategy)(nil)
+	_ CloneableStrategy = (*CloneableBudgetRetryStrategy)(nil)
+)
+
+// BudgetRetryStrategy 受 RetryBudget 限制的重试策略
+// 只有被包装的 Strategy 允许重试，并且 RetryBudget 有余量的时候才会重试
+// 每一个 BudgetRetryStrategy 代表一次请求，所以每次调用都应该创建一个新的 BudgetRetryStrategy，
+// 而 RetryBudget 在所有调用间共享
+type BudgetRetryStrategy struct {
+	strategy Strategy
+	budget   *RetryBudget
+}
+
+// NewBudgetRetryStrategy 创建一个 BudgetRetryStrategy，并在 budget 中记录一次请求
+func NewBudgetRetryStrategy(strategy Strategy, budget *RetryBudget) *BudgetRetryStrategy {
+	budget.recordRequest()
+	return &BudgetRetryStrategy{
+		strategy: strategy,
+		budget:   budget,
+	}
+}
+
+func (s *BudgetRetryStrategy) Next() (time.Duration, bool) {
+	interval, ok := s.strategy.Next()
+	if !ok || !s.budget.tryRetry() {
+		return 0, false
+	}
+	return interval, true
+}
+
+func (s *BudgetRetryStrategy) Report(err error) Strategy {
+	s.strategy = s.strategy.Report(err)
+	return s
+}
+
+// CloneableBudgetRetryStrategy 可以复用的 BudgetRetryStrategy，被包装的 Strategy 必须是 CloneableStrategy
+// 它一般作为模板使用，本身并不代表一次请求，只有 Clone 和 Reset 才会在 RetryBudget 中记录请求，
+// 所以直接使用模板发起请求之前需要先调用 Reset
+type CloneableBudgetRetryStrategy struct {
+	*BudgetRetryStrategy
+	strategy CloneableStrategy
+}
+
+// NewCloneableBudgetRetryStrategy 创建一个 CloneableBudgetRetryStrategy，此时不会在 budget 中记录请求
+func NewCloneableBudgetRetryStrategy(strategy CloneableStrategy, budget *RetryBudget) *CloneableBudgetRetryStrategy {
+	return &CloneableBudgetRetryStrategy{
+		BudgetRetryStrategy: &BudgetRetryStrategy{
+			strategy: strategy,
+			budget:   budget,
+		},
+		strategy: strategy,
+	}
+}
+
+// Report 将结果转发给被包装的 Strategy
+// 如果被包装的 Strategy 返回了新的 CloneableStrategy，那么之后的 Clone 和 Reset 都作用在新的 Strategy 上
+func (s *CloneableBudgetRetryStrategy) Report(err error) Strategy {
+	s.BudgetRetryStrategy.Report(err)
+	if cs, ok := s.BudgetRetryStrategy.strategy.(CloneableStrategy); ok {
+		s.strategy = cs
+	}
+	return s
+}
+
+// Clone 返回一个新的 Strategy，代表一次新的请求
+// 如果被包装的 Strategy 的 Clone 结果不再是 CloneableStrategy，那么返回 BudgetRetryStrategy
+func (s *CloneableBudgetRetryStrategy) Clone() Strategy {
+	inner := s.strategy.Clone()
+	if cs, ok := inner.(CloneableStrategy); ok {
+		s.budget.recordRequest()
+		return NewCloneableBudgetRetryStrategy(cs, s.budget)
+	}
+	return NewBudgetRetryStrategy(inner, s.budget)
+}
+
+// Reset 重置被包装的 Strategy，并在 RetryBudget 中记录一次新的请求
+func (s *CloneableBudgetRetryStrategy) Reset() {
+	s.strategy.Reset()
+	s.BudgetRetryStrategy.strategy = s.strategy
+	s.budget.recordRequest()
+}
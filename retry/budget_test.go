@@ -0,0 +1,192 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRetryBudget(t *testing.T) {
+	testCases := []struct {
+		name    string
+		ratio   float64
+		window  time.Duration
+		wantErr error
+	}{
+		{
+			name:    "ratio小于0",
+			ratio:   -0.1,
+			window:  time.Second,
+			wantErr: fmt.Errorf("ekit: 无效的重试预算比例 %v, 预期值应大于等于 0", -0.1),
+		},
+		{
+			name:    "window等于0",
+			ratio:   0.1,
+			wantErr: fmt.Errorf("ekit: 无效的重试预算窗口 %d, 预期值应大于 0", 0),
+		},
+		{
+			name:   "正常创建",
+			ratio:  0.1,
+			window: time.Second,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewRetryBudget(tc.ratio, 1, tc.window)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	budget, err := NewRetryBudget(0.1, 1, 10*time.Second)
+	require.NoError(t, err)
+	now := time.Unix(1000, 0)
+	budget.now = func() time.Time { return now }
+
+	// 没有请求时依然有 minRetriesPerSecond * 10 次重试
+	for i := 0; i < 10; i++ {
+		assert.True(t, budget.tryRetry())
+	}
+	assert.False(t, budget.tryRetry())
+
+	// 每 10 个请求多一次重试
+	for i := 0; i < 20; i++ {
+		budget.recordRequest()
+	}
+	assert.True(t, budget.tryRetry())
+	assert.True(t, budget.tryRetry())
+	assert.False(t, budget.tryRetry())
+
+	// 窗口滑过之后旧的重试不再计入
+	now = now.Add(5 * time.Second)
+	assert.False(t, budget.tryRetry())
+	now = now.Add(5 * time.Second)
+	for i := 0; i < 10; i++ {
+		assert.True(t, budget.tryRetry())
+	}
+	assert.False(t, budget.tryRetry())
+}
+
+func TestBudgetRetryStrategy(t *testing.T) {
+	budget, err := NewRetryBudget(0.5, 0, time.Minute)
+	require.NoError(t, err)
+
+	newStrategy := func() Strategy {
+		s, err := NewFixedIntervalRetryStrategy(time.Millisecond, 3)
+		require.NoError(t, err)
+		return NewBudgetRetryStrategy(s, budget)
+	}
+
+	calls := 0
+	bizErr := errors.New("biz error")
+	err = RetryWithContext(context.Background(), newStrategy(), func(ctx context.Context) error {
+		calls++
+		return bizErr
+	})
+	assert.ErrorIs(t, err, bizErr)
+	// 只有一个请求，预算只够 0 次重试
+	assert.Equal(t, 1, calls)
+
+	newStrategy()
+	newStrategy()
+	calls = 0
+	err = RetryWithContext(context.Background(), newStrategy(), func(ctx context.Context) error {
+		calls++
+		return bizErr
+	})
+	assert.ErrorIs(t, err, bizErr)
+	// 一共 4 个请求，预算够 2 次重试
+	assert.Equal(t, 3, calls)
+
+	// Report 会转发给被包装的 Strategy
+	s := &reportRecorder{Strategy: MockStrategy{}}
+	bs := NewBudgetRetryStrategy(s, budget)
+	assert.Equal(t, bs, bs.Report(bizErr))
+	assert.Equal(t, []error{bizErr}, s.reports)
+}
+
+func TestBudgetRetryStrategy_Clone(t *testing.T) {
+	budget, err := NewRetryBudget(1, 0, time.Minute)
+	require.NoError(t, err)
+	base, err := NewFixedIntervalRetryStrategy(time.Millisecond, 1)
+	require.NoError(t, err)
+	// 模板本身不记录请求
+	s := NewCloneableBudgetRetryStrategy(base, budget)
+	assert.False(t, budget.tryRetry())
+	// 每个 Clone 都记录了一次请求，所以预算足够每个请求重试一次
+	// 直接使用模板的时候需要先 Reset，记录模板自身代表的请求
+	s.Reset()
+	testCloneableStrategy(t, s, []time.Duration{time.Millisecond})
+	assert.Equal(t, s, s.Report(errors.New("biz error")))
+
+	// Report 返回新的 Strategy 之后，Reset 作用在新的 Strategy 上
+	inner := &switchingStrategy{next: &cloneableRecorder{reportRecorder: &reportRecorder{Strategy: MockStrategy{}}}}
+	s = NewCloneableBudgetRetryStrategy(inner, budget)
+	s.Report(errors.New("biz error"))
+	s.Reset()
+	assert.Equal(t, Strategy(inner.next), s.BudgetRetryStrategy.strategy)
+	assert.True(t, inner.next.reset)
+
+	// 被包装的 Strategy 的 Clone 结果不是 CloneableStrategy
+	budget, err = NewRetryBudget(1, 0, time.Minute)
+	require.NoError(t, err)
+	clone := NewCloneableBudgetRetryStrategy(&nonCloneableClone{}, budget).Clone()
+	_, ok := clone.(CloneableStrategy)
+	assert.False(t, ok)
+	_, ok = clone.(*BudgetRetryStrategy)
+	assert.True(t, ok)
+}
+
+// nonCloneableClone 的 Clone 返回一个普通的 Strategy
+type nonCloneableClone struct {
+	MockStrategy
+}
+
+func (n *nonCloneableClone) Clone() Strategy {
+	return MockStrategy{}
+}
+
+func (n *nonCloneableClone) Reset() {}
+
+// switchingStrategy 的 Report 返回另一个 CloneableStrategy
+type switchingStrategy struct {
+	nonCloneableClone
+	next *cloneableRecorder
+}
+
+func (s *switchingStrategy) Report(err error) Strategy {
+	return s.next
+}
+
+type cloneableRecorder struct {
+	*reportRecorder
+	reset bool
+}
+
+func (c *cloneableRecorder) Clone() Strategy {
+	return c
+}
+
+func (c *cloneableRecorder) Reset() {
+	c.reset = true
+}
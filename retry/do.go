@@ -0,0 +1,78 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ecodeclub/ekit/bean/option"
+)
+
+// ErrUnexpectedResult 业务没有返回 error，但是返回的结果需要重试，例如 HTTP 503
+var ErrUnexpectedResult = errors.New("ekit: 业务返回的结果需要重试")
+
+// Do 与 RetryWithContext 的语义一致，但是 fn 可以返回一个结果
+// fn 成功时返回 fn 的结果，否则返回 T 的零值以及 RetryWithContext 会返回的 error
+func Do[T any](ctx context.Context,
+	s Strategy,
+	fn func(ctx context.Context) (T, error),
+	opts ...option.Option[Options]) (T, error) {
+	var res T
+	err := RetryWithContext(ctx, s, func(ctx context.Context) error {
+		val, err := fn(ctx)
+		if err == nil {
+			res = val
+		}
+		return err
+	}, opts...)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return res, nil
+}
+
+// DoRetryIfResult 与 Do 类似，但是 fn 没有返回 error 时，如果 retryIfResult 返回 true，那么也会重试
+// 这一次调用会被视为失败，对应的 error 是 ErrUnexpectedResult，它同样会被 Strategy.Report、熔断器和 WithRetryIf 处理
+// 如果因为重试耗尽等原因返回时，最后一次调用的结果依然需要重试，那么会返回该结果以及对应的 error
+func DoRetryIfResult[T any](ctx context.Context,
+	s Strategy,
+	fn func(ctx context.Context) (T, error),
+	retryIfResult func(res T) bool,
+	opts ...option.Option[Options]) (T, error) {
+	var res T
+	// 最后一次调用是否成功，但是结果需要重试
+	var unexpected bool
+	err := RetryWithContext(ctx, s, func(ctx context.Context) error {
+		val, err := fn(ctx)
+		unexpected = false
+		if err != nil {
+			return err
+		}
+		res = val
+		if retryIfResult(val) {
+			unexpected = true
+			return fmt.Errorf("%w", ErrUnexpectedResult)
+		}
+		return nil
+	}, opts...)
+	if err != nil && !unexpected {
+		var zero T
+		return zero, err
+	}
+	return res, err
+}
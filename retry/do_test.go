@@ -0,0 +1,144 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDo(t *testing.T) {
+	bizErr := errors.New("biz error")
+	testCases := []struct {
+		name string
+		fn   func(calls int) (string, error)
+
+		wantVal   string
+		wantErr   error
+		wantCalls int
+	}{
+		{
+			name: "重试之后成功",
+			fn: func(calls int) (string, error) {
+				if calls < 3 {
+					return "ignored", bizErr
+				}
+				return "hello", nil
+			},
+			wantVal:   "hello",
+			wantCalls: 3,
+		},
+		{
+			name: "重试耗尽",
+			fn: func(calls int) (string, error) {
+				return "ignored", bizErr
+			},
+			wantErr:   bizErr,
+			wantCalls: 4,
+		},
+		{
+			name: "Permanent不重试",
+			fn: func(calls int) (string, error) {
+				return "ignored", Permanent(bizErr)
+			},
+			wantErr:   bizErr,
+			wantCalls: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := NewFixedIntervalRetryStrategy(time.Millisecond, 3)
+			require.NoError(t, err)
+			calls := 0
+			val, err := Do[string](context.Background(), s, func(ctx context.Context) (string, error) {
+				calls++
+				return tc.fn(calls)
+			})
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantVal, val)
+			assert.Equal(t, tc.wantCalls, calls)
+		})
+	}
+}
+
+func TestDoRetryIfResult(t *testing.T) {
+	bizErr := errors.New("biz error")
+	retryIfUnavailable := func(code int) bool {
+		return code == http.StatusServiceUnavailable
+	}
+	testCases := []struct {
+		name  string
+		codes []int
+		errs  []error
+
+		wantVal   int
+		wantErr   error
+		wantCalls int
+	}{
+		{
+			name:      "结果需要重试",
+			codes:     []int{http.StatusServiceUnavailable, 0, http.StatusOK},
+			errs:      []error{nil, bizErr, nil},
+			wantVal:   http.StatusOK,
+			wantCalls: 3,
+		},
+		{
+			name:      "重试耗尽时返回最后一次的结果",
+			codes:     []int{0, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+			errs:      []error{bizErr, nil, nil},
+			wantVal:   http.StatusServiceUnavailable,
+			wantErr:   ErrUnexpectedResult,
+			wantCalls: 3,
+		},
+		{
+			name:      "重试耗尽时最后一次调用失败",
+			codes:     []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, 0},
+			errs:      []error{nil, nil, bizErr},
+			wantErr:   bizErr,
+			wantCalls: 3,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := NewFixedIntervalRetryStrategy(time.Millisecond, 2)
+			require.NoError(t, err)
+			calls := 0
+			val, err := DoRetryIfResult[int](context.Background(), s, func(ctx context.Context) (int, error) {
+				calls++
+				return tc.codes[calls-1], tc.errs[calls-1]
+			}, retryIfUnavailable)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantVal, val)
+			assert.Equal(t, tc.wantCalls, calls)
+		})
+	}
+}
+
+func ExampleDo() {
+	s, _ := NewFixedIntervalRetryStrategy(time.Millisecond, 3)
+	val, err := Do[string](context.Background(), s, func(ctx context.Context) (string, error) {
+		return "hello, world", nil
+	})
+	fmt.Println(val, err)
+	// Output:
+	// hello, world <nil>
+}
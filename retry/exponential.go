@@ -22,7 +22,7 @@ import (
 	"github.com/ecodeclub/ekit/internal/errs"
 )
 
-var _ Strategy = (*ExponentialBackoffRetryStrategy)(nil)
+var _ CloneableStrategy = (*ExponentialBackoffRetryStrategy)(nil)
 
 // ExponentialBackoffRetryStrategy 指数退避重试
 type ExponentialBackoffRetryStrategy struct {
@@ -71,3 +71,18 @@ func (s *ExponentialBackoffRetryStrategy) Next() (time.Duration, bool) {
 	}
 	return 0, false
 }
+
+func (s *ExponentialBackoffRetryStrategy) Clone() Strategy {
+	return &ExponentialBackoffRetryStrategy{
+		initialInterval: s.initialInterval,
+		maxInterval:     s.maxInterval,
+		maxRetries:      s.maxRetries,
+	}
+}
+
+func (s *ExponentialBackoffRetryStrategy) Reset() {
+	atomic.StoreInt32(&s.retries, 0)
+	if _, ok := s.maxIntervalReached.Load().(bool); ok {
+		s.maxIntervalReached.Store(false)
+	}
+}
@@ -137,6 +137,12 @@ func TestExponentialBackoffRetryStrategy_Next4InfiniteRetry(t *testing.T) {
 	})
 }
 
+func TestExponentialBackoffRetryStrategy_Clone(t *testing.T) {
+	s, err := NewExponentialBackoffRetryStrategy(time.Second, 3*time.Second, 4)
+	require.NoError(t, err)
+	testCloneableStrategy(t, s, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second})
+}
+
 func ExampleExponentialBackoffRetryStrategy_Next() {
 	// 注意，因为在例子里面我们设置初始的重试间隔是 1s，最大重试间隔是 5s
 	// 所以在前面四次，重试间隔都是在增长的，每次变为原来的2倍。
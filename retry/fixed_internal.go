@@ -21,7 +21,7 @@ import (
 	"github.com/ecodeclub/ekit/internal/errs"
 )
 
-var _ Strategy = (*FixedIntervalRetryStrategy)(nil)
+var _ CloneableStrategy = (*FixedIntervalRetryStrategy)(nil)
 
 // FixedIntervalRetryStrategy 等间隔重试
 type FixedIntervalRetryStrategy struct {
@@ -51,3 +51,14 @@ func (s *FixedIntervalRetryStrategy) Next() (time.Duration, bool) {
 func (s *FixedIntervalRetryStrategy) Report(err error) Strategy {
 	return s
 }
+
+func (s *FixedIntervalRetryStrategy) Clone() Strategy {
+	return &FixedIntervalRetryStrategy{
+		maxRetries: s.maxRetries,
+		interval:   s.interval,
+	}
+}
+
+func (s *FixedIntervalRetryStrategy) Reset() {
+	atomic.StoreInt32(&s.retries, 0)
+}
@@ -17,6 +17,7 @@ package retry
 import (
 	"context"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -166,6 +167,44 @@ func testNext4InfiniteRetry(t *testing.T, maxRetries int32) {
 	assert.Equal(t, wantIntervals, intervals)
 }
 
+func TestFixedIntervalRetryStrategy_Clone(t *testing.T) {
+	s, err := NewFixedIntervalRetryStrategy(time.Second, 2)
+	require.NoError(t, err)
+	testCloneableStrategy(t, s, []time.Duration{time.Second, time.Second})
+}
+
+// testCloneableStrategy 验证 Clone 得到的 Strategy 互不影响，并且 Reset 之后可以再次使用
+// s 必须是刚创建的 Strategy，want 是 s 依次返回的所有重试间隔
+func testCloneableStrategy(t *testing.T, s CloneableStrategy, want []time.Duration) {
+	intervals := func(s Strategy) []time.Duration {
+		res := make([]time.Duration, 0, len(want))
+		for {
+			interval, ok := s.Next()
+			if !ok {
+				return res
+			}
+			res = append(res, interval)
+		}
+	}
+
+	// 模板被多个请求并发使用
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, want, intervals(s.Clone()))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, want, intervals(s))
+	_, ok := s.Next()
+	assert.False(t, ok)
+	s.Reset()
+	assert.Equal(t, want, intervals(s))
+}
+
 func ExampleFixedIntervalRetryStrategy_Next() {
 	retry, err := NewFixedIntervalRetryStrategy(time.Second, 3)
 	if err != nil {
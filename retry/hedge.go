@@ -0,0 +1,241 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ecodeclub/ekit/internal/errs"
+)
+
+type hedgeResult[T any] struct {
+	val T
+	err error
+}
+
+// latencyObserver 是需要感知成功请求耗时的 Strategy，例如 PercentileHedgeStrategy
+// 耗时是整个 Hedge 调用的耗时，从第一次调用 fn 开始计算，而不是胜出的那次调用的耗时
+type latencyObserver interface {
+	Observe(latency time.Duration)
+}
+
+// Hedge 对冲请求，用于降低读请求的长尾延迟
+// Hedge 会立刻调用一次 fn，如果在 s.Next 返回的间隔之后 fn 仍然没有返回，那么会并发地再调用一次 fn，
+// 直到 s.Next 返回 false。所有的调用中第一个成功的结果会被返回，其余的调用会收到被取消的 ctx。
+// 如果某一次调用失败，并且此时没有其它调用在执行，那么不等待间隔，立刻发起下一次调用。
+// Hedge 在以下情况下返回：
+// 1. 某一次调用成功
+// 2. 所有的调用都失败，并且 s.Next 返回 false，此时返回 *RetryExhaustedError，其中包含每一次调用返回的 error
+// 3. 某一次调用返回了 Permanent 标记的 error
+// 4. ctx 被取消或者超时
+// 每一次调用的结果都会通过 Strategy.Report 通知 s
+// 注意：fn 必须是幂等的
+func Hedge[T any](ctx context.Context, s Strategy, fn func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+	hedgeCtx, cancel := context.WithCancel(ctx)
+	// 返回之后仍在执行的调用会收到被取消的 ctx
+	defer cancel()
+
+	results := make(chan hedgeResult[T])
+	running := 0
+	// 按照返回的顺序记录每一次失败的调用返回的 error
+	var attemptErrs []error
+	launch := func() {
+		running++
+		go func() {
+			val, err := fn(hedgeCtx)
+			select {
+			case results <- hedgeResult[T]{val: val, err: err}:
+			case <-hedgeCtx.Done():
+			}
+		}()
+	}
+
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	// 下一次对冲的信号，为 nil 表示不再对冲
+	var hedgeC <-chan time.Time
+	scheduleNext := func() {
+		interval, ok := s.Next()
+		if !ok {
+			hedgeC = nil
+			return
+		}
+		if timer == nil {
+			timer = time.NewTimer(interval)
+		} else {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(interval)
+		}
+		hedgeC = timer.C
+	}
+
+	start := time.Now()
+	launch()
+	scheduleNext()
+	for {
+		select {
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		case <-hedgeC:
+			launch()
+			scheduleNext()
+		case res := <-results:
+			running--
+			s = s.Report(res.err)
+			if res.err == nil {
+				if o, ok := s.(latencyObserver); ok {
+					o.Observe(time.Since(start))
+				}
+				return res.val, nil
+			}
+			if IsPermanent(res.err) {
+				return zero, unwrapPermanent(res.err)
+			}
+			attemptErrs = append(attemptErrs, res.err)
+			if running > 0 {
+				continue
+			}
+			if hedgeC == nil {
+				return zero, &RetryExhaustedError{Errors: attemptErrs}
+			}
+			// 所有的调用都失败了，不必等待，立刻发起下一次调用
+			launch()
+			scheduleNext()
+		}
+	}
+}
+
+var _ CloneableStrategy = (*PercentileHedgeStrategy)(nil)
+
+// PercentileHedgeStrategy 根据历史耗时决定对冲间隔的 Strategy
+// 对冲间隔是最近 windowSize 个成功请求耗时的 percentile 分位数，例如 p95，
+// 也就是说只有耗时排在最后 5% 的请求才会触发对冲
+// 在还没有耗时数据的时候，对冲间隔为 defaultInterval
+// 通过 Clone 得到的 PercentileHedgeStrategy 共享耗时数据，所以应该为每个客户端创建一个模板，每次调用时 Clone
+type PercentileHedgeStrategy struct {
+	percentile      float64
+	defaultInterval time.Duration
+	// 最多对冲多少次，不包括第一次调用
+	maxHedges int32
+	hedges    int32
+	window    *latencyWindow
+}
+
+// NewPercentileHedgeStrategy 创建一个 PercentileHedgeStrategy
+// percentile 的取值范围为 (0, 1]，例如 0.95 表示使用 p95 作为对冲间隔
+func NewPercentileHedgeStrategy(percentile float64, windowSize int,
+	defaultInterval time.Duration, maxHedges int32) (*PercentileHedgeStrategy, error) {
+	if percentile <= 0 || percentile > 1 {
+		return nil, errs.NewErrInvalidPercentile(percentile)
+	}
+	if windowSize < 1 {
+		return nil, errs.NewErrInvalidWindowSize(windowSize)
+	}
+	if defaultInterval <= 0 {
+		return nil, errs.NewErrInvalidIntervalValue(defaultInterval)
+	}
+	return &PercentileHedgeStrategy{
+		percentile:      percentile,
+		defaultInterval: defaultInterval,
+		maxHedges:       maxHedges,
+		window:          &latencyWindow{latencies: make([]time.Duration, 0, windowSize)},
+	}, nil
+}
+
+func (s *PercentileHedgeStrategy) Next() (time.Duration, bool) {
+	if atomic.AddInt32(&s.hedges, 1) > s.maxHedges {
+		return 0, false
+	}
+	if interval, ok := s.window.percentile(s.percentile); ok {
+		return interval, true
+	}
+	return s.defaultInterval, true
+}
+
+func (s *PercentileHedgeStrategy) Report(err error) Strategy {
+	return s
+}
+
+// Observe 记录一次成功请求的耗时，Hedge 会自动调用
+// 对冲过的请求记录的是从第一次调用开始的耗时，否则分位数会因为对冲而越来越小
+func (s *PercentileHedgeStrategy) Observe(latency time.Duration) {
+	s.window.add(latency)
+}
+
+// Clone 返回一个共享耗时数据的 PercentileHedgeStrategy
+func (s *PercentileHedgeStrategy) Clone() Strategy {
+	return &PercentileHedgeStrategy{
+		percentile:      s.percentile,
+		defaultInterval: s.defaultInterval,
+		maxHedges:       s.maxHedges,
+		window:          s.window,
+	}
+}
+
+func (s *PercentileHedgeStrategy) Reset() {
+	atomic.StoreInt32(&s.hedges, 0)
+}
+
+// latencyWindow 保存最近的请求耗时
+type latencyWindow struct {
+	mutex     sync.Mutex
+	latencies []time.Duration
+	pos       int
+}
+
+func (w *latencyWindow) add(latency time.Duration) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if len(w.latencies) < cap(w.latencies) {
+		w.latencies = append(w.latencies, latency)
+		return
+	}
+	w.latencies[w.pos] = latency
+	w.pos = (w.pos + 1) % len(w.latencies)
+}
+
+func (w *latencyWindow) percentile(p float64) (time.Duration, bool) {
+	w.mutex.Lock()
+	if len(w.latencies) == 0 {
+		w.mutex.Unlock()
+		return 0, false
+	}
+	sorted := make([]time.Duration, len(w.latencies))
+	copy(sorted, w.latencies)
+	w.mutex.Unlock()
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	idx := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx], true
+}
@@ -0,0 +1,222 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ekit/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedge(t *testing.T) {
+	bizErr := errors.New("biz error")
+	testCases := []struct {
+		name string
+		// 第 i 次调用的行为
+		fn func(ctx context.Context, i int32) (int, error)
+
+		wantVal   int
+		wantErr   error
+		wantCalls int32
+	}{
+		{
+			name: "第一次调用就成功",
+			fn: func(ctx context.Context, i int32) (int, error) {
+				return 1, nil
+			},
+			wantVal:   1,
+			wantCalls: 1,
+		},
+		{
+			name: "第一次调用太慢，对冲的调用成功",
+			fn: func(ctx context.Context, i int32) (int, error) {
+				if i == 1 {
+					// 失败者会收到被取消的 ctx
+					<-ctx.Done()
+					return 0, ctx.Err()
+				}
+				return int(i), nil
+			},
+			wantVal:   2,
+			wantCalls: 2,
+		},
+		{
+			name: "失败之后立刻发起下一次调用",
+			fn: func(ctx context.Context, i int32) (int, error) {
+				if i < 3 {
+					return 0, bizErr
+				}
+				return int(i), nil
+			},
+			wantVal:   3,
+			wantCalls: 3,
+		},
+		{
+			name: "全部失败",
+			fn: func(ctx context.Context, i int32) (int, error) {
+				return 0, fmt.Errorf("第 %d 次调用失败 %w", i, bizErr)
+			},
+			wantErr:   bizErr,
+			wantCalls: 4,
+		},
+		{
+			name: "Permanent不再对冲",
+			fn: func(ctx context.Context, i int32) (int, error) {
+				return 0, Permanent(bizErr)
+			},
+			wantErr:   bizErr,
+			wantCalls: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := NewFixedIntervalRetryStrategy(10*time.Millisecond, 3)
+			require.NoError(t, err)
+			var calls int32
+			val, err := Hedge[int](context.Background(), s, func(ctx context.Context) (int, error) {
+				return tc.fn(ctx, atomic.AddInt32(&calls, 1))
+			})
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantVal, val)
+			assert.Equal(t, tc.wantCalls, atomic.LoadInt32(&calls))
+		})
+	}
+}
+
+func TestHedge_Exhausted(t *testing.T) {
+	s, err := NewFixedIntervalRetryStrategy(10*time.Millisecond, 2)
+	require.NoError(t, err)
+	var calls int32
+	_, err = Hedge[int](context.Background(), s, func(ctx context.Context) (int, error) {
+		return 0, fmt.Errorf("第 %d 次调用失败", atomic.AddInt32(&calls, 1))
+	})
+	// 与 RetryWithContext 一样返回每一次调用的 error
+	var exhausted *RetryExhaustedError
+	require.ErrorAs(t, err, &exhausted)
+	assert.Equal(t, []error{
+		fmt.Errorf("第 1 次调用失败"),
+		fmt.Errorf("第 2 次调用失败"),
+		fmt.Errorf("第 3 次调用失败"),
+	}, exhausted.Errors)
+}
+
+func TestHedge_CancelLosers(t *testing.T) {
+	s, err := NewFixedIntervalRetryStrategy(time.Millisecond, 2)
+	require.NoError(t, err)
+	var calls int32
+	cancelled := make(chan struct{}, 3)
+	val, err := Hedge[int](context.Background(), s, func(ctx context.Context) (int, error) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			<-ctx.Done()
+			cancelled <- struct{}{}
+			return 0, ctx.Err()
+		}
+		return 3, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, val)
+	<-cancelled
+	<-cancelled
+}
+
+func TestHedge_ContextTimeout(t *testing.T) {
+	s, err := NewFixedIntervalRetryStrategy(time.Millisecond, 2)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = Hedge[int](ctx, s, func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestNewPercentileHedgeStrategy(t *testing.T) {
+	_, err := NewPercentileHedgeStrategy(0, 10, time.Millisecond, 1)
+	assert.Equal(t, errs.NewErrInvalidPercentile(0), err)
+	_, err = NewPercentileHedgeStrategy(0.95, 0, time.Millisecond, 1)
+	assert.Equal(t, errs.NewErrInvalidWindowSize(0), err)
+	_, err = NewPercentileHedgeStrategy(0.95, 10, 0, 1)
+	assert.Equal(t, errs.NewErrInvalidIntervalValue(0), err)
+}
+
+func TestPercentileHedgeStrategy(t *testing.T) {
+	template, err := NewPercentileHedgeStrategy(0.9, 10, time.Second, 2)
+	require.NoError(t, err)
+
+	s := template.Clone()
+	interval, ok := s.Next()
+	assert.True(t, ok)
+	// 没有耗时数据时使用默认间隔
+	assert.Equal(t, time.Second, interval)
+
+	for i := 1; i <= 20; i++ {
+		template.Observe(time.Duration(i) * time.Millisecond)
+	}
+	// 窗口中只保留最近 10 个耗时，即 11ms 到 20ms
+	interval, ok = s.Next()
+	assert.True(t, ok)
+	assert.Equal(t, 19*time.Millisecond, interval)
+	_, ok = s.Next()
+	assert.False(t, ok)
+
+	s.(CloneableStrategy).Reset()
+	_, ok = s.Next()
+	assert.True(t, ok)
+
+	// Hedge 会记录成功请求的耗时
+	template, err = NewPercentileHedgeStrategy(0.9, 1, time.Second, 2)
+	require.NoError(t, err)
+	s = template.Clone()
+	_, err = Hedge[int](context.Background(), s, func(ctx context.Context) (int, error) {
+		time.Sleep(50 * time.Millisecond)
+		return 1, nil
+	})
+	require.NoError(t, err)
+	interval, _ = template.Next()
+	assert.True(t, interval >= 50*time.Millisecond)
+
+	// 对冲过的请求记录从第一次调用开始的耗时，而不是胜出的那次调用的耗时
+	template, err = NewPercentileHedgeStrategy(0.9, 1, 20*time.Millisecond, 2)
+	require.NoError(t, err)
+	var calls int32
+	_, err = Hedge[int](context.Background(), template.Clone(), func(ctx context.Context) (int, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		}
+		return 1, nil
+	})
+	require.NoError(t, err)
+	interval, _ = template.Next()
+	assert.True(t, interval >= 20*time.Millisecond)
+}
+
+func ExampleHedge() {
+	template, _ := NewPercentileHedgeStrategy(0.95, 100, 10*time.Millisecond, 2)
+	val, err := Hedge[string](context.Background(), template.Clone(), func(ctx context.Context) (string, error) {
+		return "hello, world", nil
+	})
+	fmt.Println(val, err)
+	// Output:
+	// hello, world <nil>
+}
@@ -0,0 +1,167 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/ecodeclub/ekit/bean/option"
+	"github.com/ecodeclub/ekit/internal/errs"
+)
+
+var _ CloneableStrategy = (*JitterBackoffRetryStrategy)(nil)
+
+type jitterMode int
+
+const (
+	fullJitter jitterMode = iota
+	equalJitter
+	decorrelatedJitter
+)
+
+// JitterBackoffRetryStrategy 带随机抖动的指数退避重试
+// 避免大量客户端在故障恢复之后按照相同的节奏同时重试
+// 参考 https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+type JitterBackoffRetryStrategy struct {
+	mode jitterMode
+	// 初始重试间隔
+	initialInterval time.Duration
+	// 最大重试间隔
+	maxInterval time.Duration
+	// 最大重试次数，如果是 0 或负数，表示无限重试
+	maxRetries int32
+	// 当前重试次数
+	retries int32
+	// 上一次的重试间隔，只有 decorrelated jitter 使用
+	prev int64
+	// 返回 [0, n) 之间的随机数
+	int63n func(n int64) int64
+}
+
+// NewFullJitterBackoffRetryStrategy 创建 full jitter 重试策略
+// 重试间隔在 [0, min(maxInterval, initialInterval * 2^n)] 之间随机
+func NewFullJitterBackoffRetryStrategy(initialInterval, maxInterval time.Duration, maxRetries int32,
+	opts ...option.Option[JitterBackoffRetryStrategy]) (*JitterBackoffRetryStrategy, error) {
+	return newJitterBackoffRetryStrategy(fullJitter, initialInterval, maxInterval, maxRetries, opts...)
+}
+
+// NewEqualJitterBackoffRetryStrategy 创建 equal jitter 重试策略
+// 重试间隔的一半是确定的指数退避间隔，另一半随机，即在 [d/2, d] 之间随机，d = min(maxInterval, initialInterval * 2^n)
+func NewEqualJitterBackoffRetryStrategy(initialInterval, maxInterval time.Duration, maxRetries int32,
+	opts ...option.Option[JitterBackoffRetryStrategy]) (*JitterBackoffRetryStrategy, error) {
+	return newJitterBackoffRetryStrategy(equalJitter, initialInterval, maxInterval, maxRetries, opts...)
+}
+
+// NewDecorrelatedJitterBackoffRetryStrategy 创建 decorrelated jitter 重试策略
+// 重试间隔在 [initialInterval, 上一次重试间隔 * 3] 之间随机，并且不超过 maxInterval
+func NewDecorrelatedJitterBackoffRetryStrategy(initialInterval, maxInterval time.Duration, maxRetries int32,
+	opts ...option.Option[JitterBackoffRetryStrategy]) (*JitterBackoffRetryStrategy, error) {
+	return newJitterBackoffRetryStrategy(decorrelatedJitter, initialInterval, maxInterval, maxRetries, opts...)
+}
+
+func newJitterBackoffRetryStrategy(mode jitterMode, initialInterval, maxInterval time.Duration, maxRetries int32,
+	opts ...option.Option[JitterBackoffRetryStrategy]) (*JitterBackoffRetryStrategy, error) {
+	if initialInterval <= 0 {
+		return nil, errs.NewErrInvalidIntervalValue(initialInterval)
+	} else if initialInterval > maxInterval {
+		return nil, errs.NewErrInvalidMaxIntervalValue(maxInterval, initialInterval)
+	}
+	s := &JitterBackoffRetryStrategy{
+		mode:            mode,
+		initialInterval: initialInterval,
+		maxInterval:     maxInterval,
+		maxRetries:      maxRetries,
+		prev:            int64(initialInterval),
+		int63n:          rand.Int63n,
+	}
+	option.Apply(s, opts...)
+	return s, nil
+}
+
+// WithJitterRandom 设置随机数来源，int63n 需要返回 [0, n) 之间的随机数
+// 默认使用 math/rand 的 Int63n，测试时可以注入确定的随机数来源
+// 注意 int63n 会被并发调用
+func WithJitterRandom(int63n func(n int64) int64) option.Option[JitterBackoffRetryStrategy] {
+	return func(s *JitterBackoffRetryStrategy) {
+		s.int63n = int63n
+	}
+}
+
+func (s *JitterBackoffRetryStrategy) Next() (time.Duration, bool) {
+	retries := atomic.AddInt32(&s.retries, 1)
+	if s.maxRetries > 0 && retries > s.maxRetries {
+		return 0, false
+	}
+	switch s.mode {
+	case fullJitter:
+		return s.random(0, s.backoff(retries)), true
+	case equalJitter:
+		d := s.backoff(retries)
+		return s.random(d/2, d), true
+	default:
+		prev := time.Duration(atomic.LoadInt64(&s.prev))
+		upper := prev * 3
+		// 溢出或者超过最大重试间隔
+		if upper/3 != prev || upper > s.maxInterval {
+			upper = s.maxInterval
+		}
+		interval := s.random(s.initialInterval, upper)
+		atomic.StoreInt64(&s.prev, int64(interval))
+		return interval, true
+	}
+}
+
+func (s *JitterBackoffRetryStrategy) Report(err error) Strategy {
+	return s
+}
+
+func (s *JitterBackoffRetryStrategy) Clone() Strategy {
+	return &JitterBackoffRetryStrategy{
+		mode:            s.mode,
+		initialInterval: s.initialInterval,
+		maxInterval:     s.maxInterval,
+		maxRetries:      s.maxRetries,
+		prev:            int64(s.initialInterval),
+		int63n:          s.int63n,
+	}
+}
+
+func (s *JitterBackoffRetryStrategy) Reset() {
+	atomic.StoreInt32(&s.retries, 0)
+	atomic.StoreInt64(&s.prev, int64(s.initialInterval))
+}
+
+// backoff 返回第 retries 次重试不带抖动的指数退避间隔
+func (s *JitterBackoffRetryStrategy) backoff(retries int32) time.Duration {
+	interval := s.initialInterval
+	for i := int32(1); i < retries; i++ {
+		interval *= 2
+		// 溢出或当前重试间隔大于最大重试间隔
+		if interval <= 0 || interval > s.maxInterval {
+			return s.maxInterval
+		}
+	}
+	return interval
+}
+
+// random 返回 [min, max] 之间的随机间隔
+func (s *JitterBackoffRetryStrategy) random(min, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+	return min + time.Duration(s.int63n(int64(max-min)+1))
+}
@@ -0,0 +1,141 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/ekit/bean/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type jitterConstructor func(initialInterval, maxInterval time.Duration, maxRetries int32,
+	opts ...option.Option[JitterBackoffRetryStrategy]) (*JitterBackoffRetryStrategy, error)
+
+func TestNewJitterBackoffRetryStrategy(t *testing.T) {
+	constructors := map[string]jitterConstructor{
+		"full":         NewFullJitterBackoffRetryStrategy,
+		"equal":        NewEqualJitterBackoffRetryStrategy,
+		"decorrelated": NewDecorrelatedJitterBackoffRetryStrategy,
+	}
+	for name, newStrategy := range constructors {
+		newStrategy := newStrategy
+		t.Run(name, func(t *testing.T) {
+			_, err := newStrategy(0, time.Second, 3)
+			assert.Equal(t, fmt.Errorf("ekit: 无效的间隔时间 %d, 预期值应大于 0", 0), err)
+			_, err = newStrategy(time.Second, time.Millisecond, 3)
+			assert.Equal(t, fmt.Errorf("ekit: 最大重试间隔的时间 [%d] 应大于等于初始重试的间隔时间 [%d] ", time.Millisecond, time.Second), err)
+			s, err := newStrategy(time.Millisecond, time.Second, 3)
+			require.NoError(t, err)
+			assert.NotNil(t, s.int63n)
+		})
+	}
+}
+
+func TestJitterBackoffRetryStrategy_Next(t *testing.T) {
+	// 总是返回最大值或者最小值，使得结果是确定的
+	maxRandom := WithJitterRandom(func(n int64) int64 { return n - 1 })
+	minRandom := WithJitterRandom(func(n int64) int64 { return 0 })
+	testCases := []struct {
+		name        string
+		newStrategy jitterConstructor
+		opt         option.Option[JitterBackoffRetryStrategy]
+
+		wantIntervals []time.Duration
+	}{
+		{
+			name:          "full jitter 取最大值",
+			newStrategy:   NewFullJitterBackoffRetryStrategy,
+			opt:           maxRandom,
+			wantIntervals: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:          "full jitter 取最小值",
+			newStrategy:   NewFullJitterBackoffRetryStrategy,
+			opt:           minRandom,
+			wantIntervals: []time.Duration{0, 0, 0, 0, 0},
+		},
+		{
+			name:          "equal jitter 取最大值",
+			newStrategy:   NewEqualJitterBackoffRetryStrategy,
+			opt:           maxRandom,
+			wantIntervals: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:        "equal jitter 取最小值",
+			newStrategy: NewEqualJitterBackoffRetryStrategy,
+			opt:         minRandom,
+			wantIntervals: []time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second,
+				2500 * time.Millisecond, 2500 * time.Millisecond},
+		},
+		{
+			name:          "decorrelated jitter 取最大值",
+			newStrategy:   NewDecorrelatedJitterBackoffRetryStrategy,
+			opt:           maxRandom,
+			wantIntervals: []time.Duration{3 * time.Second, 5 * time.Second, 5 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:          "decorrelated jitter 取最小值",
+			newStrategy:   NewDecorrelatedJitterBackoffRetryStrategy,
+			opt:           minRandom,
+			wantIntervals: []time.Duration{time.Second, time.Second, time.Second, time.Second, time.Second},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := tc.newStrategy(time.Second, 5*time.Second, 5, tc.opt)
+			require.NoError(t, err)
+			intervals := make([]time.Duration, 0, len(tc.wantIntervals))
+			for {
+				interval, ok := s.Next()
+				if !ok {
+					break
+				}
+				intervals = append(intervals, interval)
+			}
+			assert.Equal(t, tc.wantIntervals, intervals)
+			assert.Equal(t, s, s.Report(nil))
+		})
+	}
+}
+
+func TestJitterBackoffRetryStrategy_Range(t *testing.T) {
+	// 使用默认的随机数来源，并且无限重试
+	s, err := NewDecorrelatedJitterBackoffRetryStrategy(time.Millisecond, time.Second, 0)
+	require.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		interval, ok := s.Next()
+		require.True(t, ok)
+		assert.True(t, interval >= time.Millisecond && interval <= time.Second)
+	}
+
+	s, err = NewFullJitterBackoffRetryStrategy(time.Millisecond, time.Second, 0)
+	require.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		interval, ok := s.Next()
+		require.True(t, ok)
+		assert.True(t, interval >= 0 && interval <= time.Second)
+	}
+}
+
+func TestJitterBackoffRetryStrategy_Clone(t *testing.T) {
+	maxRandom := WithJitterRandom(func(n int64) int64 { return n - 1 })
+	s, err := NewDecorrelatedJitterBackoffRetryStrategy(time.Second, 5*time.Second, 3, maxRandom)
+	require.NoError(t, err)
+	testCloneableStrategy(t, s, []time.Duration{3 * time.Second, 5 * time.Second, 5 * time.Second})
+}
@@ -0,0 +1,55 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import "errors"
+
+// permanentError 代表不可重试的 error
+type permanentError struct {
+	err error
+}
+
+func (p *permanentError) Error() string {
+	return p.err.Error()
+}
+
+func (p *permanentError) Unwrap() error {
+	return p.err
+}
+
+// Permanent 将 err 标记为不可重试，例如参数错误、鉴权失败
+// bizFunc 返回这样的 error 之后，RetryWithContext 会立刻返回 err，不再重试
+// err 为 nil 时返回 nil
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent 判断 err 是否被 Permanent 标记为不可重试
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// unwrapPermanent 去掉最外层的 Permanent 标记
+// 如果 Permanent 标记被用户再次包装过，那么保留用户的包装
+func unwrapPermanent(err error) error {
+	if pe, ok := err.(*permanentError); ok {
+		return pe.err
+	}
+	return err
+}
@@ -16,34 +16,77 @@ package retry
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
-	"github.com/ecodeclub/ekit/internal/errs"
+	"github.com/ecodeclub/ekit/bean/option"
 )
 
 // Retry 会在以下条件满足的情况下返回：
 // 1. 重试达到了最大次数，而后返回重试耗尽的错误
-// 2. ctx 被取消或者超时
+// 2. ctx 被取消或者超时，此时返回 ctx.Err()
 // 3. bizFunc 没有返回 error
 // 而只要 bizFunc 返回 error，就会尝试重试
+// 如果 bizFunc 需要感知 ctx，或者需要区分哪些 error 可以重试，请使用 RetryWithContext
 func Retry(ctx context.Context,
 	s Strategy,
 	bizFunc func() error) error {
+	err := RetryWithContext(ctx, s, func(ctx context.Context) error {
+		return bizFunc()
+	})
+	// 保持原有的行为，调用者可能直接使用 err == ctx.Err() 判断
+	if e, ok := err.(*RetryExhaustedError); ok && e.Cause != nil {
+		return e.Cause
+	}
+	return err
+}
+
+// RetryWithContext 与 Retry 类似，但是会把 ctx 传给 bizFunc，并且只重试可以重试的 error：
+// 1. 使用 Permanent 标记的 error 不会被重试，RetryWithContext 会去掉标记之后返回
+// 2. 设置了 WithRetryIf 的话，只有 retryIf 返回 true 的 error 才会被重试，否则直接返回该 error
+// 每一次调用 bizFunc 的结果，包括 nil，都会通过 Strategy.Report 通知 s，
+// 以便 AdaptiveTimeoutRetryStrategy 这一类策略根据结果调整
+// 设置了 WithCircuitBreaker 的话，每一次调用都需要经过熔断器，熔断器打开时不再重试，直接返回 ErrCircuitOpen
+// 重试耗尽，或者等待重试期间 ctx 被取消或者超时，都会返回 *RetryExhaustedError，其中包含每一次调用返回的 error，
+// 后者的 Cause 为 ctx.Err()，所以依然可以通过 errors.Is(err, context.DeadlineExceeded) 判断，
+// 但是不能再使用 err == ctx.Err() 判断，这一点与 Retry 不同
+func RetryWithContext(ctx context.Context,
+	s Strategy,
+	bizFunc func(ctx context.Context) error,
+	opts ...option.Option[Options]) error {
+	o := &Options{}
+	option.Apply(o, opts...)
 	var ticker *time.Ticker
 	defer func() {
 		if ticker != nil {
 			ticker.Stop()
 		}
 	}()
+	var attemptErrs []error
 	for {
-		err := bizFunc()
+		err := o.attempt(ctx, bizFunc)
+		if errors.Is(err, ErrCircuitOpen) {
+			return err
+		}
+		s = s.Report(err)
 		// 直接退出
 		if err == nil {
 			return nil
 		}
+		if IsPermanent(err) {
+			return unwrapPermanent(err)
+		}
+		if !o.retryable(err) {
+			return err
+		}
+		attemptErrs = append(attemptErrs, err)
 		duration, ok := s.Next()
 		if !ok {
-			return errs.NewErrRetryExhausted(err)
+			return &RetryExhaustedError{Errors: attemptErrs}
+		}
+		if o.onRetry != nil {
+			o.onRetry(len(attemptErrs), err, duration)
 		}
 		if ticker == nil {
 			ticker = time.NewTicker(duration)
@@ -53,8 +96,108 @@ func Retry(ctx context.Context,
 		select {
 		case <-ctx.Done():
 			// 超时或者被取消了，直接返回
-			return ctx.Err()
+			return &RetryExhaustedError{Errors: attemptErrs, Cause: ctx.Err()}
 		case <-ticker.C:
 		}
 	}
 }
+
+// Options 是 RetryWithContext 的可选配置
+type Options struct {
+	retryIf        func(err error) bool
+	breaker        *CircuitBreaker
+	onRetry        func(attempt int, err error, next time.Duration)
+	attemptTimeout time.Duration
+}
+
+// WithRetryIf 设置判断 error 是否可以重试的方法
+// 默认情况下，除了使用 Permanent 包装的 error 之外，所有的 error 都会被重试
+func WithRetryIf(retryIf func(err error) bool) option.Option[Options] {
+	return func(o *Options) {
+		o.retryIf = retryIf
+	}
+}
+
+// WithCircuitBreaker 设置熔断器，多次调用之间可以共享同一个熔断器
+// 只有可以重试的 error 才会被熔断器记为失败，例如使用 Permanent 标记的参数错误不会导致熔断
+func WithCircuitBreaker(breaker *CircuitBreaker) option.Option[Options] {
+	return func(o *Options) {
+		o.breaker = breaker
+	}
+}
+
+// WithOnRetry 设置每次决定重试时的回调，可以用于记录日志或者上报监控
+// attempt 是刚刚失败的那次调用的序号，从 1 开始；err 是该次调用返回的 error；next 是距离下一次调用的间隔
+func WithOnRetry(onRetry func(attempt int, err error, next time.Duration)) option.Option[Options] {
+	return func(o *Options) {
+		o.onRetry = onRetry
+	}
+}
+
+// WithAttemptTimeout 设置每一次调用的超时时间
+// 每一次调用都会收到一个从 RetryWithContext 的 ctx 派生、带有超时时间的 ctx，
+// 某一次调用超时并不会影响后续的重试，而 RetryWithContext 的 ctx 则控制了整个重试过程
+func WithAttemptTimeout(timeout time.Duration) option.Option[Options] {
+	return func(o *Options) {
+		o.attemptTimeout = timeout
+	}
+}
+
+func (o *Options) retryable(err error) bool {
+	return o.retryIf == nil || o.retryIf(err)
+}
+
+// attempt 调用一次 bizFunc，设置了熔断器的话需要经过熔断器
+func (o *Options) attempt(ctx context.Context, bizFunc func(ctx context.Context) error) error {
+	if o.attemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.attemptTimeout)
+		defer cancel()
+	}
+	if o.breaker == nil {
+		return bizFunc(ctx)
+	}
+	done, err := o.breaker.Allow()
+	if err != nil {
+		return err
+	}
+	defer reportPanic(done)
+	err = bizFunc(ctx)
+	if err != nil && !IsPermanent(err) && o.retryable(err) {
+		done(err)
+	} else {
+		done(nil)
+	}
+	return err
+}
+
+// RetryExhaustedError 重试耗尽或者重试被 ctx 中断时返回的 error，Hedge 的所有调用都失败时也会返回它
+type RetryExhaustedError struct {
+	// Errors 每一次调用返回的 error，按照调用的顺序排列
+	Errors []error
+	// Cause 重试被 ctx 中断时为 ctx.Err()，重试耗尽时为 nil
+	Cause error
+}
+
+func (e *RetryExhaustedError) Error() string {
+	var last error
+	if len(e.Errors) > 0 {
+		last = e.Errors[len(e.Errors)-1]
+	}
+	if e.Cause != nil {
+		return fmt.Sprintf("ekit: 重试被中断 %v，共调用 %d 次，业务返回的最后一个 error %v",
+			e.Cause, len(e.Errors), last)
+	}
+	return fmt.Sprintf("ekit: 超过最大重试次数，共调用 %d 次，业务返回的最后一个 error %v",
+		len(e.Errors), last)
+}
+
+// Unwrap 返回每一次调用返回的 error 以及 Cause，所以 errors.Is 和 errors.As 可以匹配其中任意一个
+func (e *RetryExhaustedError) Unwrap() []error {
+	if e.Cause == nil {
+		return e.Errors
+	}
+	res := make([]error, 0, len(e.Errors)+1)
+	res = append(res, e.Errors...)
+	return append(res, e.Cause)
+}
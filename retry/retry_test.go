@@ -21,7 +21,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ecodeclub/ekit/bean/option"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRetry(t *testing.T) {
@@ -66,6 +68,205 @@ func TestRetry(t *testing.T) {
 	}
 }
 
+func TestRetry_Cancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	s, err := NewFixedIntervalRetryStrategy(time.Second, 3)
+	require.NoError(t, err)
+	err = Retry(ctx, s, func() error {
+		return errors.New("biz error")
+	})
+	// Retry 依旧直接返回 ctx.Err()
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestRetryWithContext(t *testing.T) {
+	bizErr := errors.New("biz error")
+	testCases := []struct {
+		name     string
+		errs     []error
+		opts     []option.Option[Options]
+		strategy Strategy
+
+		wantCalls   int
+		wantReports []error
+		wantError   error
+	}{
+		{
+			name:        "重试之后成功",
+			errs:        []error{bizErr, bizErr, nil},
+			wantCalls:   3,
+			wantReports: []error{bizErr, bizErr, nil},
+		},
+		{
+			name:        "Permanent不重试",
+			errs:        []error{bizErr, Permanent(bizErr)},
+			wantCalls:   2,
+			wantReports: []error{bizErr, Permanent(bizErr)},
+			wantError:   bizErr,
+		},
+		{
+			name:        "被包装的Permanent不重试",
+			errs:        []error{fmt.Errorf("wrap: %w", Permanent(bizErr))},
+			wantCalls:   1,
+			wantReports: []error{fmt.Errorf("wrap: %w", Permanent(bizErr))},
+			wantError:   bizErr,
+		},
+		{
+			name: "retryIf返回false不重试",
+			errs: []error{bizErr, context.DeadlineExceeded},
+			opts: []option.Option[Options]{WithRetryIf(func(err error) bool {
+				return errors.Is(err, bizErr)
+			})},
+			wantCalls:   2,
+			wantReports: []error{bizErr, context.DeadlineExceeded},
+			wantError:   context.DeadlineExceeded,
+		},
+		{
+			name:        "重试耗尽",
+			errs:        []error{bizErr, bizErr, bizErr, bizErr, bizErr},
+			wantCalls:   4,
+			wantReports: []error{bizErr, bizErr, bizErr, bizErr},
+			wantError:   bizErr,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+			base, err := NewFixedIntervalRetryStrategy(time.Millisecond, 3)
+			require.NoError(t, err)
+			s := &reportRecorder{Strategy: base}
+			calls := 0
+			err = RetryWithContext(ctx, s, func(bizCtx context.Context) error {
+				assert.Equal(t, ctx, bizCtx)
+				calls++
+				return tc.errs[calls-1]
+			}, tc.opts...)
+			assert.ErrorIs(t, err, tc.wantError)
+			if tc.wantError == nil {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.wantCalls, calls)
+			assert.Equal(t, tc.wantReports, s.reports)
+		})
+	}
+}
+
+func TestRetryWithContext_Cancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	s, err := NewFixedIntervalRetryStrategy(time.Second, 3)
+	require.NoError(t, err)
+	bizErr := errors.New("biz error")
+	err = RetryWithContext(ctx, s, func(ctx context.Context) error {
+		return bizErr
+	})
+	// 依然可以拿到每一次调用返回的 error
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorIs(t, err, bizErr)
+	var exhausted *RetryExhaustedError
+	require.ErrorAs(t, err, &exhausted)
+	assert.Equal(t, []error{bizErr}, exhausted.Errors)
+	assert.Equal(t, context.DeadlineExceeded, exhausted.Cause)
+	assert.Equal(t, "ekit: 重试被中断 context deadline exceeded，共调用 1 次，业务返回的最后一个 error biz error", err.Error())
+}
+
+func TestRetryWithContext_Adaptive(t *testing.T) {
+	// 失败会被报告给 AdaptiveTimeoutRetryStrategy，达到阈值之后不再重试
+	s := NewAdaptiveTimeoutRetryStrategy(MockStrategy{}, 1, 3)
+	s.strategy = func() Strategy {
+		res, _ := NewFixedIntervalRetryStrategy(time.Millisecond, 10)
+		return res
+	}()
+	calls := 0
+	err := RetryWithContext(context.Background(), s, func(ctx context.Context) error {
+		calls++
+		return errors.New("biz error")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetryWithContext_OnRetry(t *testing.T) {
+	type retryRecord struct {
+		attempt int
+		err     error
+		next    time.Duration
+	}
+	s, err := NewFixedIntervalRetryStrategy(time.Millisecond, 2)
+	require.NoError(t, err)
+	var records []retryRecord
+	calls := 0
+	err = RetryWithContext(context.Background(), s, func(ctx context.Context) error {
+		calls++
+		return fmt.Errorf("第 %d 次调用失败", calls)
+	}, WithOnRetry(func(attempt int, err error, next time.Duration) {
+		records = append(records, retryRecord{attempt: attempt, err: err, next: next})
+	}))
+
+	assert.Equal(t, []retryRecord{
+		{attempt: 1, err: fmt.Errorf("第 1 次调用失败"), next: time.Millisecond},
+		{attempt: 2, err: fmt.Errorf("第 2 次调用失败"), next: time.Millisecond},
+	}, records)
+
+	var exhausted *RetryExhaustedError
+	require.ErrorAs(t, err, &exhausted)
+	assert.Equal(t, []error{
+		fmt.Errorf("第 1 次调用失败"),
+		fmt.Errorf("第 2 次调用失败"),
+		fmt.Errorf("第 3 次调用失败"),
+	}, exhausted.Errors)
+	assert.Equal(t, "ekit: 超过最大重试次数，共调用 3 次，业务返回的最后一个 error 第 3 次调用失败", err.Error())
+}
+
+func TestRetryWithContext_AttemptTimeout(t *testing.T) {
+	s, err := NewFixedIntervalRetryStrategy(time.Millisecond, 2)
+	require.NoError(t, err)
+	calls := 0
+	err = RetryWithContext(context.Background(), s, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			// 单次调用超时之后还会继续重试
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		_, ok := ctx.Deadline()
+		assert.True(t, ok)
+		return nil
+	}, WithAttemptTimeout(10*time.Millisecond))
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetryExhaustedError_Error(t *testing.T) {
+	// 零值也可以输出
+	assert.Equal(t, "ekit: 超过最大重试次数，共调用 0 次，业务返回的最后一个 error <nil>", (&RetryExhaustedError{}).Error())
+	assert.Equal(t, "ekit: 重试被中断 context canceled，共调用 0 次，业务返回的最后一个 error <nil>",
+		(&RetryExhaustedError{Cause: context.Canceled}).Error())
+}
+
+func TestPermanent(t *testing.T) {
+	assert.Nil(t, Permanent(nil))
+	bizErr := errors.New("biz error")
+	err := Permanent(bizErr)
+	assert.True(t, IsPermanent(err))
+	assert.ErrorIs(t, err, bizErr)
+	assert.Equal(t, bizErr.Error(), err.Error())
+	assert.False(t, IsPermanent(bizErr))
+}
+
+type reportRecorder struct {
+	Strategy
+	reports []error
+}
+
+func (r *reportRecorder) Report(err error) Strategy {
+	r.reports = append(r.reports, err)
+	return r
+}
+
 func ExampleRetry() {
 	// 这是你的业务
 	bizFunc := func() error {
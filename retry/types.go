@@ -23,3 +23,16 @@ type Strategy interface {
 	Next() (time.Duration, bool)
 	Report(err error) Strategy
 }
+
+// CloneableStrategy 可以复用的 Strategy
+// FixedIntervalRetryStrategy 这一类 Strategy 会记录已经重试的次数，所以同一个实例不能被多次调用共享。
+// 可以将配置好的 CloneableStrategy 作为模板保存在客户端中，每次调用时通过 Clone 得到一个新的 Strategy
+type CloneableStrategy interface {
+	Strategy
+	// Clone 返回一个配置相同、处于初始状态的 Strategy，返回的 Strategy 与原来的 Strategy 互不影响
+	// Clone 可以被并发调用
+	Clone() Strategy
+	// Reset 将 Strategy 重置为初始状态，以便再次使用
+	// Reset 不能和 Next 并发调用
+	Reset()
+}
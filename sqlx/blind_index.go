@@ -0,0 +1,81 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlx
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+var errBlindIndexKeyTooShort = errors.New("ekit BlindIndex的key至少需要 16 byte")
+
+// BlindIndex 代表加密列的盲索引，用于按照加密列进行等值查询
+// EncryptColumn 每次加密都使用随机 nonce，同一个明文每次加密的结果都不一样，所以无法按照密文查询。
+// BlindIndex 的值是明文的 HMAC-SHA256，同一个明文总是得到同一个值，因此可以建立索引并进行等值查询。
+// 一般的用法是同时存储两列：一列是 EncryptColumn，用于读取明文；另一列是 BlindIndex，用于查询，例如：
+//
+//	INSERT INTO users(phone, phone_idx) VALUES(?, ?)
+//	args: EncryptColumn[string]{Val: phone, Valid: true, Key: encKey}, BlindIndex[string]{Val: phone, Valid: true, Key: idxKey}
+//	SELECT phone FROM users WHERE phone_idx = ?
+//	args: BlindIndex[string]{Val: phone, Valid: true, Key: idxKey}
+//
+// 注意 BlindIndex 会泄露信息，使用之前需要确认可以接受：
+//   - 相等性：能访问数据库的人可以知道哪些行的明文相同，并且可以统计每个值出现的频率，
+//     对于取值范围很小的数据（例如性别、状态），这几乎等同于明文
+//   - 字典攻击：拿到 Key 的人可以枚举明文计算 HMAC 进行比对，手机号、身份证号之类的数据空间并不大，
+//     所以 Key 需要和加密的 key 一样妥善保管，并且不能和加密的 key 相同，不同列应该使用不同的 Key
+//   - 只支持等值查询：不支持范围查询、模糊查询和排序
+//
+// 可以通过 Len 截断 HMAC 来减少泄露：截断之后不同的明文可能得到同一个值，
+// 数据库只能知道「可能相等」，查询结果需要解密 EncryptColumn 之后再过滤一次
+// BlindIndex 的序列化规则和 EncryptColumn 一致，明文在计算之前不会做任何规范化，
+// 例如大小写、空格等需要业务自己统一处理
+// BlindIndex 只用于写入和查询，不支持 Scan，因为无法从 HMAC 还原明文
+type BlindIndex[T any] struct {
+	Val   T
+	Valid bool
+	// Key 是 HMAC 的 key，至少需要 16 byte
+	Key string
+	// Len 是保留的 HMAC 的长度，单位是 byte，取值范围 [0, 32]，0 代表不截断
+	Len int
+}
+
+// Value 返回明文的 HMAC-SHA256
+// Valid 为 false 时返回 nil，即写入 NULL
+func (b BlindIndex[T]) Value() (driver.Value, error) {
+	if !b.Valid {
+		return nil, nil
+	}
+	if len(b.Key) < 16 {
+		return nil, errBlindIndexKeyTooShort
+	}
+	if b.Len < 0 || b.Len > sha256.Size {
+		return nil, fmt.Errorf("ekit: BlindIndex 的长度应在 [0, %d] 之间，实际为 %d", sha256.Size, b.Len)
+	}
+	data, err := encodeVal(b.Val)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, []byte(b.Key))
+	mac.Write(data)
+	sum := mac.Sum(nil)
+	if b.Len > 0 {
+		sum = sum[:b.Len]
+	}
+	return sum, nil
+}
@@ -0,0 +1,122 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlx
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlindIndex_Value(t *testing.T) {
+	testCases := []struct {
+		name    string
+		idx     BlindIndex[string]
+		wantLen int
+		wantNil bool
+		wantErr bool
+	}{
+		{
+			name:    "无效值",
+			idx:     BlindIndex[string]{Val: "abc", Key: testKeyV1},
+			wantNil: true,
+		},
+		{
+			name:    "key太短",
+			idx:     BlindIndex[string]{Val: "abc", Valid: true, Key: "ABC"},
+			wantErr: true,
+		},
+		{
+			name:    "长度为负数",
+			idx:     BlindIndex[string]{Val: "abc", Valid: true, Key: testKeyV1, Len: -1},
+			wantErr: true,
+		},
+		{
+			name:    "长度太长",
+			idx:     BlindIndex[string]{Val: "abc", Valid: true, Key: testKeyV1, Len: 33},
+			wantErr: true,
+		},
+		{
+			name:    "不截断",
+			idx:     BlindIndex[string]{Val: "abc", Valid: true, Key: testKeyV1},
+			wantLen: 32,
+		},
+		{
+			name:    "截断",
+			idx:     BlindIndex[string]{Val: "abc", Valid: true, Key: testKeyV1, Len: 8},
+			wantLen: 8,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			val, err := tc.idx.Value()
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tc.wantNil {
+				assert.Nil(t, val)
+				return
+			}
+			assert.Len(t, val, tc.wantLen)
+		})
+	}
+}
+
+func TestBlindIndex_Deterministic(t *testing.T) {
+	value := func(idx BlindIndex[int]) []byte {
+		val, err := idx.Value()
+		require.NoError(t, err)
+		return val.([]byte)
+	}
+	full := value(BlindIndex[int]{Val: 123, Valid: true, Key: testKeyV1})
+	assert.Equal(t, full, value(BlindIndex[int]{Val: 123, Valid: true, Key: testKeyV1}))
+	assert.NotEqual(t, full, value(BlindIndex[int]{Val: 124, Valid: true, Key: testKeyV1}))
+	assert.NotEqual(t, full, value(BlindIndex[int]{Val: 123, Valid: true, Key: testKeyV2}))
+	assert.Equal(t, full[:8], value(BlindIndex[int]{Val: 123, Valid: true, Key: testKeyV1, Len: 8}))
+}
+
+func TestBlindIndex_Query(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, "CREATE TABLE users(id INTEGER PRIMARY KEY, phone BLOB, phone_idx BLOB)")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "CREATE INDEX idx_phone ON users(phone_idx)")
+	require.NoError(t, err)
+
+	for _, phone := range []string{"13800000000", "13900000000"} {
+		_, err = db.ExecContext(ctx, "INSERT INTO users(phone, phone_idx) VALUES(?, ?)",
+			EncryptColumn[string]{Val: phone, Valid: true, Key: testKeyV1},
+			BlindIndex[string]{Val: phone, Valid: true, Key: testKeyV2})
+		require.NoError(t, err)
+	}
+
+	var phone EncryptColumn[string]
+	phone.Key = testKeyV1
+	err = db.QueryRowContext(ctx, "SELECT phone FROM users WHERE phone_idx = ?",
+		BlindIndex[string]{Val: "13900000000", Valid: true, Key: testKeyV2}).Scan(&phone)
+	require.NoError(t, err)
+	assert.Equal(t, "13900000000", phone.Val)
+
+	err = db.QueryRowContext(ctx, "SELECT phone FROM users WHERE phone_idx = ?",
+		BlindIndex[string]{Val: "13700000000", Valid: true, Key: testKeyV2}).Scan(&phone)
+	assert.Equal(t, sql.ErrNoRows, err)
+}
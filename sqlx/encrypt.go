@@ -32,10 +32,19 @@ import (
 // EncryptColumn 并不打算使用极其难破解的加密算法
 // 而是选择使用 AES GCM 模式。
 // 如果你觉得安全性不够，那么你可以考虑自己实现类似的结构体.
+// 设置了 Keyring 的话，会使用 Keyring 加解密，Key 将被忽略，
+// 此时密文头部会记录 key ID，从而支持轮换 key，参考 Keyring
+// 设置了 Encryptor 的话，会委托给 Encryptor 加解密，Key 和 Keyring 都将被忽略，
+// 这样可以选择其它算法或者使用 EnvelopeEncryptor 避免在结构体中持有密钥
 type EncryptColumn[T any] struct {
-	Val   T
-	Valid bool
-	Key   string
+	Val       T
+	Valid     bool
+	Key       string
+	Keyring   *Keyring
+	Encryptor Encryptor
+
+	// Scan 时使用的 key ID，不带 key ID 的旧密文为空
+	keyID string
 }
 
 var errInvalid = errors.New("ekit EncryptColumn无效")
@@ -48,10 +57,24 @@ func (e EncryptColumn[T]) Value() (driver.Value, error) {
 	if !e.Valid {
 		return nil, errInvalid
 	}
-	if len(e.Key) != 16 && len(e.Key) != 24 && len(e.Key) != 32 {
+	if e.Encryptor == nil && e.Keyring == nil && !validKeyLen(e.Key) {
 		return nil, errKeyLengthInvalid
 	}
-	var val any = e.Val
+	b, err := encodeVal(e.Val)
+	if err != nil {
+		return nil, err
+	}
+	if e.Encryptor != nil {
+		return e.Encryptor.Encrypt(b)
+	}
+	if e.Keyring != nil {
+		return e.Keyring.encrypt(b)
+	}
+	return aesEncrypt(e.Key, b)
+}
+
+// encodeVal 将 val 序列化，基本类型按照大端序编码，其它类型按照 JSON 序列化
+func encodeVal(val any) ([]byte, error) {
 	var err error
 	var b []byte
 	switch valT := val.(type) {
@@ -75,12 +98,9 @@ func (e EncryptColumn[T]) Value() (driver.Value, error) {
 		err = binary.Write(buffer, binary.BigEndian, tmp)
 		b = buffer.Bytes()
 	default:
-		b, err = json.Marshal(e.Val)
+		b, err = json.Marshal(val)
 	}
-	if err != nil {
-		return nil, err
-	}
-	return e.aesEncrypt(b)
+	return b, err
 }
 
 // Scan 方法会把写入的数据转化进行解密，
@@ -90,9 +110,9 @@ func (e *EncryptColumn[T]) Scan(src any) error {
 	var b []byte
 	switch value := src.(type) {
 	case []byte:
-		b, err = e.aesDecrypt(value)
+		b, err = e.decrypt(value)
 	case string:
-		b, err = e.aesDecrypt([]byte(value))
+		b, err = e.decrypt([]byte(value))
 	default:
 		return fmt.Errorf("ekit：EncryptColumn.Scan 不支持 src 类型 %v", src)
 	}
@@ -132,32 +152,65 @@ func (e *EncryptColumn[T]) setValAfterDecrypt(deEncrypt []byte) error {
 	return err
 }
 
-func (e *EncryptColumn[T]) aesEncrypt(data []byte) ([]byte, error) {
-	newCipher, err := aes.NewCipher([]byte(e.Key))
-	if err != nil {
-		return nil, err
+// NeedsReEncrypt 判断 Scan 得到的数据是否需要使用 Keyring 的 active key 重新加密
+// 可以在读取数据之后判断，如果需要的话再写回数据库，从而逐步完成 key 的轮换
+func (e *EncryptColumn[T]) NeedsReEncrypt() bool {
+	return e.Encryptor == nil && e.Keyring != nil && e.Valid && e.keyID != e.Keyring.ActiveKeyID()
+}
+
+func (e *EncryptColumn[T]) decrypt(data []byte) ([]byte, error) {
+	if e.Encryptor != nil {
+		return e.Encryptor.Decrypt(data)
 	}
-	gcm, err := cipher.NewGCM(newCipher)
+	if e.Keyring != nil {
+		res, keyID, err := e.Keyring.decrypt(data)
+		e.keyID = keyID
+		return res, err
+	}
+	return aesDecrypt(e.Key, data)
+}
+
+func validKeyLen(key string) bool {
+	return len(key) == 16 || len(key) == 24 || len(key) == 32
+}
+
+func aesEncrypt(key string, data []byte) ([]byte, error) {
+	aead, err := newAESGCM([]byte(key))
 	if err != nil {
 		return nil, err
 	}
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+	return aeadSeal(aead, data)
+}
+
+func aesDecrypt(key string, data []byte) ([]byte, error) {
+	aead, err := newAESGCM([]byte(key))
+	if err != nil {
 		return nil, err
 	}
-	encrypted := gcm.Seal(nonce, nonce, data, nil)
-	return encrypted, nil
+	return aeadOpen(aead, data)
 }
 
-func (e *EncryptColumn[T]) aesDecrypt(data []byte) ([]byte, error) {
-	newCipher, err := aes.NewCipher([]byte(e.Key))
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	newCipher, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
-	gcm, err := cipher.NewGCM(newCipher)
-	if err != nil {
+	return cipher.NewGCM(newCipher)
+}
+
+// aeadSeal 使用随机 nonce 加密，返回 nonce | 密文
+func aeadSeal(aead cipher.AEAD, data []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
 		return nil, err
 	}
-	nonce, cipherData := data[:gcm.NonceSize()], data[gcm.NonceSize():]
-	return gcm.Open(nil, nonce, cipherData, nil)
+	return aead.Seal(nonce, nonce, data, nil), nil
+}
+
+func aeadOpen(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errInvalid
+	}
+	nonce, cipherData := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, cipherData, nil)
 }
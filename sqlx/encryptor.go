@@ -0,0 +1,75 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlx
+
+import (
+	"crypto/cipher"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Encryptor 负责 EncryptColumn 的加解密
+// Encrypt 返回的密文需要包含解密所需的全部信息（例如 nonce），
+// 并且同一个 Encryptor 实例会被并发使用
+type Encryptor interface {
+	Encrypt(plain []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// AEADEncryptor 基于 cipher.AEAD 实现 Encryptor
+// 每次加密都会生成随机 nonce，密文格式为 nonce | 密文
+type AEADEncryptor struct {
+	aead cipher.AEAD
+}
+
+// NewAEADEncryptor 使用任意 cipher.AEAD 创建 Encryptor
+func NewAEADEncryptor(aead cipher.AEAD) *AEADEncryptor {
+	return &AEADEncryptor{aead: aead}
+}
+
+// NewAESGCMEncryptor 创建使用 AES GCM 模式的 Encryptor，key 必须是 16/24/32 byte
+// 它和直接设置 EncryptColumn.Key 产生的密文格式一致，可以互相解密
+func NewAESGCMEncryptor(key string) (*AEADEncryptor, error) {
+	if !validKeyLen(key) {
+		return nil, errKeyLengthInvalid
+	}
+	aead, err := newAESGCM([]byte(key))
+	if err != nil {
+		return nil, err
+	}
+	return NewAEADEncryptor(aead), nil
+}
+
+// NewChaCha20Poly1305Encryptor 创建使用 ChaCha20-Poly1305 的 Encryptor，key 必须是 32 byte
+// 在没有 AES 硬件加速的 CPU 上，它通常比 AES GCM 更快
+func NewChaCha20Poly1305Encryptor(key string) (*AEADEncryptor, error) {
+	if len(key) != chacha20poly1305.KeySize {
+		return nil, fmt.Errorf("ekit: ChaCha20-Poly1305 仅支持 %d byte 的key", chacha20poly1305.KeySize)
+	}
+	aead, err := chacha20poly1305.New([]byte(key))
+	if err != nil {
+		return nil, err
+	}
+	return NewAEADEncryptor(aead), nil
+}
+
+func (a *AEADEncryptor) Encrypt(plain []byte) ([]byte, error) {
+	return aeadSeal(a.aead, plain)
+}
+
+func (a *AEADEncryptor) Decrypt(ciphertext []byte) ([]byte, error) {
+	return aeadOpen(a.aead, ciphertext)
+}
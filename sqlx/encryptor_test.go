@@ -0,0 +1,110 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAEADEncryptor(t *testing.T) {
+	testCases := []struct {
+		name    string
+		newFunc func(key string) (*AEADEncryptor, error)
+		key     string
+		wantErr bool
+	}{
+		{
+			name:    "AES GCM key长度不正确",
+			newFunc: NewAESGCMEncryptor,
+			key:     "ABC",
+			wantErr: true,
+		},
+		{
+			name:    "AES GCM",
+			newFunc: NewAESGCMEncryptor,
+			key:     testKeyV1,
+		},
+		{
+			name:    "ChaCha20-Poly1305 key长度不正确",
+			newFunc: NewChaCha20Poly1305Encryptor,
+			key:     testKeyV1,
+			wantErr: true,
+		},
+		{
+			name:    "ChaCha20-Poly1305",
+			newFunc: NewChaCha20Poly1305Encryptor,
+			key:     testKeyV2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			enc, err := tc.newFunc(tc.key)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			encrypted, err := EncryptColumn[string]{Val: "abc", Valid: true, Encryptor: enc}.Value()
+			require.NoError(t, err)
+			col := &EncryptColumn[string]{Encryptor: enc}
+			require.NoError(t, col.Scan(encrypted))
+			assert.Equal(t, "abc", col.Val)
+			assert.True(t, col.Valid)
+
+			// 被篡改的密文
+			tampered := append([]byte{}, encrypted.([]byte)...)
+			tampered[len(tampered)-1] ^= 1
+			assert.Error(t, col.Scan(tampered))
+			assert.Equal(t, errInvalid, col.Scan([]byte("abc")))
+		})
+	}
+}
+
+func TestEncryptColumn_EncryptorPriority(t *testing.T) {
+	enc, err := NewChaCha20Poly1305Encryptor(testKeyV2)
+	require.NoError(t, err)
+	kr, err := NewKeyring("v1", map[string]string{"v1": testKeyV1})
+	require.NoError(t, err)
+
+	// 设置了 Encryptor 之后 Key 和 Keyring 都被忽略
+	encrypted, err := EncryptColumn[int]{Val: 123, Valid: true, Key: "ABC", Keyring: kr, Encryptor: enc}.Value()
+	require.NoError(t, err)
+	col := &EncryptColumn[int]{Keyring: kr, Encryptor: enc}
+	require.NoError(t, col.Scan(encrypted))
+	assert.Equal(t, 123, col.Val)
+	assert.False(t, col.NeedsReEncrypt())
+	assert.Error(t, (&EncryptColumn[int]{Keyring: kr}).Scan(encrypted))
+}
+
+func TestNewAESGCMEncryptor_Compatible(t *testing.T) {
+	// 与直接使用 Key 加密的数据互相兼容
+	enc, err := NewAESGCMEncryptor(testKeyV1)
+	require.NoError(t, err)
+	encrypted, err := EncryptColumn[string]{Val: "abc", Valid: true, Key: testKeyV1}.Value()
+	require.NoError(t, err)
+	col := &EncryptColumn[string]{Encryptor: enc}
+	require.NoError(t, col.Scan(encrypted))
+	assert.Equal(t, "abc", col.Val)
+
+	encrypted, err = EncryptColumn[string]{Val: "abc", Valid: true, Encryptor: enc}.Value()
+	require.NoError(t, err)
+	col = &EncryptColumn[string]{Key: testKeyV1}
+	require.NoError(t, col.Scan(encrypted))
+	assert.Equal(t, "abc", col.Val)
+}
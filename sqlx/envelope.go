@@ -0,0 +1,162 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlx
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// 信封加密的密文格式：magic(3 byte) | version(1 byte) | key ID 长度(1 byte) | key ID |
+// 加密后的 data key 长度(2 byte) | 加密后的 data key | nonce | 密文
+var envelopeHeaderMagic = []byte("EKE")
+
+const (
+	envelopeHeaderVersion1 byte = 1
+	dataKeyLen                  = 32
+	// 解密时缓存的 data key 的最大数量
+	maxCachedDataKeys = 1024
+)
+
+// EnvelopeEncryptor 使用信封加密实现 Encryptor
+// 创建的时候生成一个随机的 data key，并且通过 KMSClient 使用主密钥加密 data key，
+// 之后使用 data key 按照 AES GCM 模式加密数据，加密后的 data key 和主密钥 ID 记录在密文头部。
+// 解密时通过 KMSClient 解密 data key，并且缓存解密的结果，所以不会每一行数据都请求一次 KMS。
+// 因为 Encryptor 的方法没有 context，所以解密 data key 时使用的是 context.Background()，
+// 超时之类的控制需要 KMSClient 自己处理。
+// 需要轮换 data key 或者主密钥的时候，重新创建一个 EnvelopeEncryptor 即可，旧数据依然可以解密
+type EnvelopeEncryptor struct {
+	kms    KMSClient
+	header []byte
+	aead   cipher.AEAD
+
+	mu sync.RWMutex
+	// 加密后的 data key 到 data key 的映射
+	cache map[string]cipher.AEAD
+}
+
+// NewEnvelopeEncryptor 创建一个 EnvelopeEncryptor，keyID 是主密钥在 KMS 中的 ID
+func NewEnvelopeEncryptor(ctx context.Context, kms KMSClient, keyID string) (*EnvelopeEncryptor, error) {
+	if len(keyID) == 0 || len(keyID) > maxKeyIDLen {
+		return nil, fmt.Errorf("ekit: 无效的 key ID %q，长度应在 [1, %d] 之间", keyID, maxKeyIDLen)
+	}
+	dataKey := make([]byte, dataKeyLen)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	wrapped, err := kms.WrapKey(ctx, keyID, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("ekit: KMS 加密 data key 失败 %w", err)
+	}
+	if len(wrapped) > 0xFFFF {
+		return nil, fmt.Errorf("ekit: KMS 加密后的 data key 太长，长度 %d", len(wrapped))
+	}
+	aead, err := newAESGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 0, len(envelopeHeaderMagic)+4+len(keyID)+len(wrapped))
+	header = append(header, envelopeHeaderMagic...)
+	header = append(header, envelopeHeaderVersion1, byte(len(keyID)))
+	header = append(header, keyID...)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(wrapped)))
+	header = append(header, wrapped...)
+	return &EnvelopeEncryptor{
+		kms:    kms,
+		header: header,
+		aead:   aead,
+		cache:  map[string]cipher.AEAD{string(wrapped): aead},
+	}, nil
+}
+
+func (e *EnvelopeEncryptor) Encrypt(plain []byte) ([]byte, error) {
+	encrypted, err := aeadSeal(e.aead, plain)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]byte, 0, len(e.header)+len(encrypted))
+	res = append(res, e.header...)
+	return append(res, encrypted...), nil
+}
+
+func (e *EnvelopeEncryptor) Decrypt(ciphertext []byte) ([]byte, error) {
+	keyID, wrapped, encrypted, ok := parseEnvelopeHeader(ciphertext)
+	if !ok {
+		return nil, errInvalid
+	}
+	aead, err := e.dataKey(keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return aeadOpen(aead, encrypted)
+}
+
+func (e *EnvelopeEncryptor) dataKey(keyID string, wrapped []byte) (cipher.AEAD, error) {
+	e.mu.RLock()
+	aead, ok := e.cache[string(wrapped)]
+	e.mu.RUnlock()
+	if ok {
+		return aead, nil
+	}
+	dataKey, err := e.kms.UnwrapKey(context.Background(), keyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("ekit: KMS 解密 data key 失败 %w", err)
+	}
+	aead, err = newAESGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.cache) >= maxCachedDataKeys {
+		// 简单地清空缓存，只保留当前用于加密的 data key
+		e.cache = map[string]cipher.AEAD{e.wrappedKey(): e.aead}
+	}
+	e.cache[string(wrapped)] = aead
+	return aead, nil
+}
+
+// wrappedKey 返回当前用于加密的 data key 被加密后的数据
+func (e *EnvelopeEncryptor) wrappedKey() string {
+	_, wrapped, _, _ := parseEnvelopeHeader(e.header)
+	return string(wrapped)
+}
+
+// parseEnvelopeHeader 解析信封加密的密文头部，返回主密钥 ID、加密后的 data key 以及去掉头部之后的密文
+func parseEnvelopeHeader(data []byte) (string, []byte, []byte, bool) {
+	prefixLen := len(envelopeHeaderMagic) + 2
+	if len(data) < prefixLen || !bytes.HasPrefix(data, envelopeHeaderMagic) ||
+		data[len(envelopeHeaderMagic)] != envelopeHeaderVersion1 {
+		return "", nil, nil, false
+	}
+	idLen := int(data[prefixLen-1])
+	if idLen == 0 || len(data) < prefixLen+idLen+2 {
+		return "", nil, nil, false
+	}
+	keyID := string(data[prefixLen : prefixLen+idLen])
+	data = data[prefixLen+idLen:]
+	wrappedLen := int(binary.BigEndian.Uint16(data))
+	data = data[2:]
+	if wrappedLen == 0 || len(data) < wrappedLen {
+		return "", nil, nil, false
+	}
+	return keyID, data[:wrappedLen], data[wrappedLen:], true
+}
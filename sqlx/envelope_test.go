@@ -0,0 +1,105 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlx
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingKMS 记录 UnwrapKey 的调用次数
+type countingKMS struct {
+	KMSClient
+	unwrapCnt int32
+}
+
+func (c *countingKMS) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	atomic.AddInt32(&c.unwrapCnt, 1)
+	return c.KMSClient.UnwrapKey(ctx, keyID, wrapped)
+}
+
+type errKMS struct{}
+
+func (errKMS) WrapKey(ctx context.Context, keyID string, dataKey []byte) ([]byte, error) {
+	return nil, errors.New("mock error")
+}
+
+func (errKMS) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	return nil, errors.New("mock error")
+}
+
+func newTestFileKMS(t *testing.T, keyIDs ...string) *FileKMS {
+	kms, err := NewFileKMS(filepath.Join(t.TempDir(), "kms.json"))
+	require.NoError(t, err)
+	for _, id := range keyIDs {
+		require.NoError(t, kms.CreateKey(id))
+	}
+	return kms
+}
+
+func TestNewEnvelopeEncryptor(t *testing.T) {
+	kms := newTestFileKMS(t, "k1")
+	_, err := NewEnvelopeEncryptor(context.Background(), kms, "")
+	assert.Error(t, err)
+	_, err = NewEnvelopeEncryptor(context.Background(), kms, "k2")
+	assert.Error(t, err)
+	_, err = NewEnvelopeEncryptor(context.Background(), errKMS{}, "k1")
+	assert.Error(t, err)
+}
+
+func TestEnvelopeEncryptor(t *testing.T) {
+	kms := &countingKMS{KMSClient: newTestFileKMS(t, "k1", "k2")}
+	enc1, err := NewEnvelopeEncryptor(context.Background(), kms, "k1")
+	require.NoError(t, err)
+
+	encrypted, err := EncryptColumn[string]{Val: "abc", Valid: true, Encryptor: enc1}.Value()
+	require.NoError(t, err)
+	keyID, _, _, ok := parseEnvelopeHeader(encrypted.([]byte))
+	require.True(t, ok)
+	assert.Equal(t, "k1", keyID)
+
+	// 自己加密的数据不需要请求 KMS
+	col := &EncryptColumn[string]{Encryptor: enc1}
+	require.NoError(t, col.Scan(encrypted))
+	assert.Equal(t, "abc", col.Val)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&kms.unwrapCnt))
+
+	// 轮换主密钥之后依然可以解密旧数据，并且 data key 只解密一次
+	enc2, err := NewEnvelopeEncryptor(context.Background(), kms, "k2")
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		col = &EncryptColumn[string]{Encryptor: enc2}
+		require.NoError(t, col.Scan(encrypted))
+		assert.Equal(t, "abc", col.Val)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&kms.unwrapCnt))
+
+	// 无效的密文
+	assert.Equal(t, errInvalid, col.Scan([]byte("abc")))
+	tampered := append([]byte{}, encrypted.([]byte)...)
+	tampered[len(tampered)-1] ^= 1
+	assert.Error(t, col.Scan(tampered))
+
+	// KMS 无法解密 data key
+	other, err := NewEnvelopeEncryptor(context.Background(), newTestFileKMS(t, "k1"), "k1")
+	require.NoError(t, err)
+	assert.Error(t, (&EncryptColumn[string]{Encryptor: other}).Scan(encrypted))
+}
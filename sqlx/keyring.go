@@ -0,0 +1,137 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// 带有 key ID 的密文格式：magic(3 byte) | version(1 byte) | key ID 长度(1 byte) | key ID | nonce | 密文
+// 不带头部的密文是早期版本 EncryptColumn 直接使用 Key 加密的结果
+var cipherHeaderMagic = []byte("EKC")
+
+const (
+	cipherHeaderVersion1 byte = 1
+	maxKeyIDLen               = 255
+)
+
+var errKeyNotFound = errors.New("ekit EncryptColumn找不到对应的key")
+
+// Keyring 管理 EncryptColumn 使用的多个 key，用于轮换 key
+// 加密时总是使用 active key，并且在密文头部记录 key ID；
+// 解密时根据密文头部的 key ID 选择 key，所以轮换 key 之后旧的数据依然可以解密。
+// 轮换 key 的步骤一般是：加入新的 key 并设置为 active key，
+// 然后通过 ReEncrypt 或者 EncryptColumn.NeedsReEncrypt 逐步用新的 key 重新加密旧的数据，最后再移除旧的 key
+// Keyring 创建之后是只读的，可以被并发使用
+type Keyring struct {
+	activeID string
+	keys     map[string]string
+}
+
+// NewKeyring 创建一个 Keyring
+// keys 是 key ID 到 key 的映射，key 必须是 16/24/32 byte，key ID 不能为空并且最长为 255 byte
+// activeID 是用于加密的 key 的 ID
+func NewKeyring(activeID string, keys map[string]string) (*Keyring, error) {
+	if _, ok := keys[activeID]; !ok {
+		return nil, fmt.Errorf("ekit: active key %s 不存在", activeID)
+	}
+	copied := make(map[string]string, len(keys))
+	for id, key := range keys {
+		if len(id) == 0 || len(id) > maxKeyIDLen {
+			return nil, fmt.Errorf("ekit: 无效的 key ID %q，长度应在 [1, %d] 之间", id, maxKeyIDLen)
+		}
+		if !validKeyLen(key) {
+			return nil, fmt.Errorf("%w，key ID %s", errKeyLengthInvalid, id)
+		}
+		copied[id] = key
+	}
+	return &Keyring{activeID: activeID, keys: copied}, nil
+}
+
+// ActiveKeyID 返回用于加密的 key 的 ID
+func (k *Keyring) ActiveKeyID() string {
+	return k.activeID
+}
+
+// encrypt 使用 active key 加密，并在密文头部记录 key ID
+func (k *Keyring) encrypt(data []byte) ([]byte, error) {
+	encrypted, err := aesEncrypt(k.keys[k.activeID], data)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 0, len(cipherHeaderMagic)+2+len(k.activeID)+len(encrypted))
+	header = append(header, cipherHeaderMagic...)
+	header = append(header, cipherHeaderVersion1, byte(len(k.activeID)))
+	header = append(header, k.activeID...)
+	return append(header, encrypted...), nil
+}
+
+// decrypt 解密并返回所使用的 key ID
+// 不带头部的密文会依次尝试 Keyring 中的所有 key，此时返回的 key ID 为空
+func (k *Keyring) decrypt(data []byte) ([]byte, string, error) {
+	if keyID, encrypted, ok := parseCipherHeader(data); ok {
+		if key, found := k.keys[keyID]; found {
+			res, err := aesDecrypt(key, encrypted)
+			if err == nil {
+				return res, keyID, nil
+			}
+		}
+		// 极小概率下旧密文的 nonce 恰好以 magic 开头，所以这里继续按照不带头部的密文处理
+	}
+	for _, key := range k.keys {
+		if res, err := aesDecrypt(key, data); err == nil {
+			return res, "", nil
+		}
+	}
+	return nil, "", fmt.Errorf("%w", errKeyNotFound)
+}
+
+// parseCipherHeader 解析密文头部，返回 key ID 和去掉头部之后的密文
+func parseCipherHeader(data []byte) (string, []byte, bool) {
+	prefixLen := len(cipherHeaderMagic) + 2
+	if len(data) < prefixLen || !bytes.HasPrefix(data, cipherHeaderMagic) ||
+		data[len(cipherHeaderMagic)] != cipherHeaderVersion1 {
+		return "", nil, false
+	}
+	idLen := int(data[prefixLen-1])
+	if idLen == 0 || len(data) < prefixLen+idLen {
+		return "", nil, false
+	}
+	return string(data[prefixLen : prefixLen+idLen]), data[prefixLen+idLen:], true
+}
+
+// ReEncrypt 使用 active key 重新加密 EncryptColumn 写入数据库的密文
+// 如果密文已经使用 active key 加密，那么返回原来的密文以及 false，否则返回新的密文以及 true
+// ReEncrypt 不需要知道明文的类型，适合在后台任务中批量轮换 key，例如：
+//
+//	SELECT id, encrypted FROM t
+//	val, changed, err := ReEncrypt(keyring, encrypted)
+//	if changed { UPDATE t SET encrypted = val WHERE id = ? }
+func ReEncrypt(keyring *Keyring, ciphertext []byte) ([]byte, bool, error) {
+	plain, keyID, err := keyring.decrypt(ciphertext)
+	if err != nil {
+		return nil, false, err
+	}
+	if keyID == keyring.activeID {
+		return ciphertext, false, nil
+	}
+	res, err := keyring.encrypt(plain)
+	if err != nil {
+		return nil, false, err
+	}
+	return res, true, nil
+}
@@ -0,0 +1,164 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlx
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testKeyV1 = "ABCDABCDABCDABCD"
+	testKeyV2 = "EFGHEFGHEFGHEFGHEFGHEFGHEFGHEFGH"
+)
+
+func TestNewKeyring(t *testing.T) {
+	testCases := []struct {
+		name     string
+		activeID string
+		keys     map[string]string
+		wantErr  bool
+	}{
+		{
+			name:     "active key不存在",
+			activeID: "v2",
+			keys:     map[string]string{"v1": testKeyV1},
+			wantErr:  true,
+		},
+		{
+			name:     "key长度不正确",
+			activeID: "v1",
+			keys:     map[string]string{"v1": testKeyV1, "v2": "ABC"},
+			wantErr:  true,
+		},
+		{
+			name:     "key ID太长",
+			activeID: "v1",
+			keys:     map[string]string{"v1": testKeyV1, strings.Repeat("a", 256): testKeyV2},
+			wantErr:  true,
+		},
+		{
+			name:     "key ID为空",
+			activeID: "",
+			keys:     map[string]string{"": testKeyV1},
+			wantErr:  true,
+		},
+		{
+			name:     "正常创建",
+			activeID: "v1",
+			keys:     map[string]string{"v1": testKeyV1, "v2": testKeyV2},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kr, err := NewKeyring(tc.activeID, tc.keys)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.activeID, kr.ActiveKeyID())
+		})
+	}
+}
+
+func TestEncryptColumn_Keyring(t *testing.T) {
+	krV1, err := NewKeyring("v1", map[string]string{"v1": testKeyV1})
+	require.NoError(t, err)
+	krV2, err := NewKeyring("v2", map[string]string{"v1": testKeyV1, "v2": testKeyV2})
+	require.NoError(t, err)
+
+	// 使用 v1 加密的数据带有 key ID
+	encrypted, err := EncryptColumn[string]{Val: "abc", Valid: true, Keyring: krV1}.Value()
+	require.NoError(t, err)
+	keyID, _, ok := parseCipherHeader(encrypted.([]byte))
+	require.True(t, ok)
+	assert.Equal(t, "v1", keyID)
+
+	// 轮换到 v2 之后依然可以解密，并且需要重新加密
+	col := &EncryptColumn[string]{Keyring: krV2}
+	require.NoError(t, col.Scan(encrypted))
+	assert.Equal(t, "abc", col.Val)
+	assert.True(t, col.NeedsReEncrypt())
+
+	encrypted, err = col.Value()
+	require.NoError(t, err)
+	col = &EncryptColumn[string]{Keyring: krV2}
+	require.NoError(t, col.Scan(encrypted))
+	assert.Equal(t, "abc", col.Val)
+	assert.False(t, col.NeedsReEncrypt())
+
+	// 移除 v1 之后无法解密 v1 加密的数据
+	krV2Only, err := NewKeyring("v2", map[string]string{"v2": testKeyV2})
+	require.NoError(t, err)
+	encrypted, err = EncryptColumn[string]{Val: "abc", Valid: true, Keyring: krV1}.Value()
+	require.NoError(t, err)
+	col = &EncryptColumn[string]{Keyring: krV2Only}
+	assert.ErrorIs(t, col.Scan(encrypted), errKeyNotFound)
+	assert.False(t, col.NeedsReEncrypt())
+}
+
+func TestEncryptColumn_KeyringLegacy(t *testing.T) {
+	// 不带头部的旧密文依然可以解密
+	legacy, err := EncryptColumn[int64]{Val: 123, Valid: true, Key: testKeyV1}.Value()
+	require.NoError(t, err)
+
+	kr, err := NewKeyring("v2", map[string]string{"v1": testKeyV1, "v2": testKeyV2})
+	require.NoError(t, err)
+	col := &EncryptColumn[int64]{Keyring: kr}
+	require.NoError(t, col.Scan(legacy))
+	assert.Equal(t, int64(123), col.Val)
+	assert.True(t, col.NeedsReEncrypt())
+
+	// 太短的密文
+	assert.ErrorIs(t, col.Scan([]byte("abc")), errKeyNotFound)
+	assert.Equal(t, errInvalid, (&EncryptColumn[int64]{Key: testKeyV1}).Scan([]byte("abc")))
+}
+
+func TestReEncrypt(t *testing.T) {
+	krV1, err := NewKeyring("v1", map[string]string{"v1": testKeyV1})
+	require.NoError(t, err)
+	krV2, err := NewKeyring("v2", map[string]string{"v1": testKeyV1, "v2": testKeyV2})
+	require.NoError(t, err)
+
+	v1, err := EncryptColumn[string]{Val: "abc", Valid: true, Keyring: krV1}.Value()
+	require.NoError(t, err)
+	legacy, err := EncryptColumn[string]{Val: "abc", Valid: true, Key: testKeyV1}.Value()
+	require.NoError(t, err)
+
+	for _, ciphertext := range [][]byte{v1.([]byte), legacy.([]byte)} {
+		v2, changed, err := ReEncrypt(krV2, ciphertext)
+		require.NoError(t, err)
+		assert.True(t, changed)
+		keyID, _, ok := parseCipherHeader(v2)
+		require.True(t, ok)
+		assert.Equal(t, "v2", keyID)
+
+		res, changed, err := ReEncrypt(krV2, v2)
+		require.NoError(t, err)
+		assert.False(t, changed)
+		assert.Equal(t, v2, res)
+
+		col := &EncryptColumn[string]{Keyring: krV2}
+		require.NoError(t, col.Scan(v2))
+		assert.Equal(t, "abc", col.Val)
+	}
+
+	_, _, err = ReEncrypt(krV1, []byte("abc"))
+	assert.ErrorIs(t, err, errKeyNotFound)
+}
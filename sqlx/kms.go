@@ -0,0 +1,126 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// KMSClient 代表密钥管理服务，EnvelopeEncryptor 通过它加解密 data key，
+// 主密钥始终保存在 KMS 中，不会出现在应用里
+type KMSClient interface {
+	// WrapKey 使用 keyID 对应的主密钥加密 data key
+	WrapKey(ctx context.Context, keyID string, dataKey []byte) ([]byte, error)
+	// UnwrapKey 使用 keyID 对应的主密钥解密 WrapKey 返回的数据
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+var _ KMSClient = &FileKMS{}
+
+// FileKMS 是基于本地文件的 KMSClient，只适合用于测试和本地开发
+// 主密钥以 JSON 格式保存在文件中，例如 {"key ID": "base64 编码的 32 byte 主密钥"}
+type FileKMS struct {
+	path string
+	mu   sync.RWMutex
+	keys map[string][]byte
+}
+
+// NewFileKMS 从 path 中加载主密钥，文件不存在的时候创建一个空的 FileKMS
+func NewFileKMS(path string) (*FileKMS, error) {
+	f := &FileKMS{path: path, keys: make(map[string][]byte)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var encoded map[string]string
+	if err = json.Unmarshal(data, &encoded); err != nil {
+		return nil, err
+	}
+	for id, val := range encoded {
+		key, err := base64.StdEncoding.DecodeString(val)
+		if err != nil {
+			return nil, err
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("ekit: FileKMS 主密钥 %s 的长度必须是 32 byte", id)
+		}
+		f.keys[id] = key
+	}
+	return f, nil
+}
+
+// CreateKey 生成一个随机的主密钥并写入文件，keyID 已经存在的话返回 error
+func (f *FileKMS) CreateKey(keyID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.keys[keyID]; ok {
+		return fmt.Errorf("ekit: FileKMS 主密钥 %s 已经存在", keyID)
+	}
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return err
+	}
+	encoded := make(map[string]string, len(f.keys)+1)
+	for id, val := range f.keys {
+		encoded[id] = base64.StdEncoding.EncodeToString(val)
+	}
+	encoded[keyID] = base64.StdEncoding.EncodeToString(key)
+	data, err := json.Marshal(encoded)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(f.path, data, 0o600); err != nil {
+		return err
+	}
+	f.keys[keyID] = key
+	return nil
+}
+
+func (f *FileKMS) WrapKey(_ context.Context, keyID string, dataKey []byte) ([]byte, error) {
+	key, err := f.masterKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return aesEncrypt(string(key), dataKey)
+}
+
+func (f *FileKMS) UnwrapKey(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	key, err := f.masterKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return aesDecrypt(string(key), wrapped)
+}
+
+func (f *FileKMS) masterKey(keyID string) ([]byte, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	key, ok := f.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("ekit: FileKMS 主密钥 %s 不存在", keyID)
+	}
+	return key, nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlx
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFileKMS(t *testing.T) {
+	dir := t.TempDir()
+	testCases := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name:    "不是JSON",
+			content: "abc",
+			wantErr: true,
+		},
+		{
+			name:    "不是base64",
+			content: `{"k1": "!!!"}`,
+			wantErr: true,
+		},
+		{
+			name:    "主密钥长度不正确",
+			content: `{"k1": "QUJD"}`,
+			wantErr: true,
+		},
+		{
+			name:    "正常加载",
+			content: `{"k1": "QUJDREFCQ0RBQkNEQUJDREFCQ0RBQkNEQUJDREFCQ0Q="}`,
+		},
+	}
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, string(rune('a'+i))+".json")
+			require.NoError(t, os.WriteFile(path, []byte(tc.content), 0o600))
+			_, err := NewFileKMS(path)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestFileKMS(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kms.json")
+	kms, err := NewFileKMS(path)
+	require.NoError(t, err)
+	require.NoError(t, kms.CreateKey("k1"))
+	assert.Error(t, kms.CreateKey("k1"))
+
+	ctx := context.Background()
+	wrapped, err := kms.WrapKey(ctx, "k1", []byte("data key"))
+	require.NoError(t, err)
+	_, err = kms.WrapKey(ctx, "k2", []byte("data key"))
+	assert.Error(t, err)
+
+	// 重新加载之后依然可以解密
+	kms, err = NewFileKMS(path)
+	require.NoError(t, err)
+	dataKey, err := kms.UnwrapKey(ctx, "k1", wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data key"), dataKey)
+	_, err = kms.UnwrapKey(ctx, "k2", wrapped)
+	assert.Error(t, err)
+}
@@ -16,10 +16,13 @@ package sqlx
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"reflect"
+
+	"github.com/ecodeclub/ekit/bean/option"
 )
 
 var (
@@ -38,13 +41,46 @@ type Scanner interface {
 	NextResultSet() bool
 }
 
+var _ BatchScanner = &sqlRowsScanner{}
+
+// BatchScanner 在 Scanner 的基础上支持分批扫描和流式扫描，适合处理大量数据
+type BatchScanner interface {
+	Scanner
+	// ScanBatch 扫描当前结果集最多 n 行数据，剩余数据不足 n 行时返回剩余的全部数据，
+	// 没有更多数据时返回 ErrNoMoreRows
+	ScanBatch(n int) (batch [][]any, err error)
+	// ScanStream 在一个新的 goroutine 中逐行扫描当前结果集，并将每一行发送到返回的 channel 中
+	// 扫描结束之后关闭数据 channel，并且向 error channel 发送一次结果，正常结束时为 nil，ctx 被取消时为 ctx.Err()
+	// 提前退出时需要取消 ctx，并且在收到 error channel 的结果之前不能再使用 Scanner 或者关闭 sql.Rows
+	ScanStream(ctx context.Context, bufSize int) (<-chan []any, <-chan error)
+}
+
 type sqlRowsScanner struct {
 	sqlRows             Rows
 	columnValuePointers []any
+
+	reuseBuffer bool
+	// 开启 reuseBuffer 时复用的 Scan 和 ScanBatch 的结果
+	row   []any
+	batch [][]any
+}
+
+// WithReuseRowBuffer 复用 Scan 和 ScanBatch 返回的切片，从而减少内存分配，适合逐批处理大量数据的场景
+// 开启之后，Scan 和 ScanBatch 返回的数据只在下一次调用 Scan 或者 ScanBatch 之前有效，
+// 如果需要保留数据，那么需要自己复制一份。ScanAll 和 ScanStream 不受影响
+func WithReuseRowBuffer() option.Option[sqlRowsScanner] {
+	return func(s *sqlRowsScanner) {
+		s.reuseBuffer = true
+	}
 }
 
 // NewSQLRowsScanner 返回一个Scanner
-func NewSQLRowsScanner(r Rows) (Scanner, error) {
+func NewSQLRowsScanner(r Rows, opts ...option.Option[sqlRowsScanner]) (Scanner, error) {
+	return NewSQLRowsBatchScanner(r, opts...)
+}
+
+// NewSQLRowsBatchScanner 返回一个BatchScanner
+func NewSQLRowsBatchScanner(r Rows, opts ...option.Option[sqlRowsScanner]) (BatchScanner, error) {
 	if r == nil {
 		return nil, fmt.Errorf("%w *sql.Rows不能为nil", errInvalidArgument)
 	}
@@ -61,7 +97,9 @@ func NewSQLRowsScanner(r Rows) (Scanner, error) {
 		}
 		columnValuePointers[i] = reflect.New(typ).Interface()
 	}
-	return &sqlRowsScanner{sqlRows: r, columnValuePointers: columnValuePointers}, nil
+	s := &sqlRowsScanner{sqlRows: r, columnValuePointers: columnValuePointers}
+	option.Apply(s, opts...)
+	return s, nil
 }
 
 func (s *sqlRowsScanner) NextResultSet() bool {
@@ -70,6 +108,19 @@ func (s *sqlRowsScanner) NextResultSet() bool {
 
 // Scan 返回一行
 func (s *sqlRowsScanner) Scan() ([]any, error) {
+	if !s.reuseBuffer {
+		return s.scan(nil)
+	}
+	row, err := s.scan(s.row)
+	if err != nil {
+		return nil, err
+	}
+	s.row = row
+	return row, nil
+}
+
+// scan 读取一行并写入 dst，dst 容量不够时会重新分配
+func (s *sqlRowsScanner) scan(dst []any) ([]any, error) {
 	if !s.sqlRows.Next() {
 		if err := s.sqlRows.Err(); err != nil {
 			return nil, err
@@ -81,27 +132,106 @@ func (s *sqlRowsScanner) Scan() ([]any, error) {
 	if err != nil {
 		return nil, err
 	}
-	return s.columnValues(), nil
+	return s.columnValues(dst), nil
 }
 
-func (s *sqlRowsScanner) columnValues() []any {
-	values := make([]any, len(s.columnValuePointers))
+func (s *sqlRowsScanner) columnValues(dst []any) []any {
+	if cap(dst) < len(s.columnValuePointers) {
+		dst = make([]any, len(s.columnValuePointers))
+	}
+	values := dst[:len(s.columnValuePointers)]
 	for i := 0; i < len(s.columnValuePointers); i++ {
 		val := reflect.ValueOf(s.columnValuePointers[i]).Elem().Interface()
 		// sql.RawBytes 存在内存共享的问题，所以需要执行复制
 		if rawBytes, ok := val.(sql.RawBytes); ok {
-			val = sql.RawBytes(bytes.Clone(rawBytes))
+			old, reusable := values[i].(sql.RawBytes)
+			// old 为 nil 时（例如上一行是 NULL）不能复用，否则空的 sql.RawBytes 会变成 nil
+			if reusable && old != nil && rawBytes != nil {
+				// 复用上一次的内存
+				val = append(old[:0], rawBytes...)
+			} else {
+				val = sql.RawBytes(bytes.Clone(rawBytes))
+			}
 		}
 		values[i] = val
 	}
 	return values
 }
 
+// ScanBatch 返回最多 n 行
+func (s *sqlRowsScanner) ScanBatch(n int) ([][]any, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("%w n 必须大于 0，实际为 %d", errInvalidArgument, n)
+	}
+	var batch [][]any
+	if s.reuseBuffer && cap(s.batch) >= n {
+		// 保留之前的行，从而复用它们
+		batch = s.batch[:n]
+	} else {
+		batch = make([][]any, n)
+		if s.reuseBuffer {
+			copy(batch, s.batch[:cap(s.batch)])
+		}
+	}
+	cnt := 0
+	for ; cnt < n; cnt++ {
+		var dst []any
+		if s.reuseBuffer {
+			dst = batch[cnt]
+		}
+		row, err := s.scan(dst)
+		if err != nil {
+			if errors.Is(err, ErrNoMoreRows) {
+				break
+			}
+			return nil, err
+		}
+		batch[cnt] = row
+	}
+	if s.reuseBuffer {
+		s.batch = batch
+	}
+	if cnt == 0 {
+		return nil, fmt.Errorf("%w", ErrNoMoreRows)
+	}
+	return batch[:cnt], nil
+}
+
+// ScanStream 逐行返回，每一行都是新分配的切片
+func (s *sqlRowsScanner) ScanStream(ctx context.Context, bufSize int) (<-chan []any, <-chan error) {
+	rowCh := make(chan []any, bufSize)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(rowCh)
+		for {
+			if err := ctx.Err(); err != nil {
+				errCh <- err
+				return
+			}
+			row, err := s.scan(nil)
+			if err != nil {
+				if errors.Is(err, ErrNoMoreRows) {
+					err = nil
+				}
+				errCh <- err
+				return
+			}
+			select {
+			case rowCh <- row:
+			case <-ctx.Done():
+				errCh <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return rowCh, errCh
+}
+
 // ScanAll 返回所有行
 func (s *sqlRowsScanner) ScanAll() ([][]any, error) {
 	all := make([][]any, 0, 32)
 	for {
-		columnValues, err := s.Scan()
+		columnValues, err := s.scan(nil)
 		if err != nil {
 			if errors.Is(err, ErrNoMoreRows) {
 				break
@@ -18,10 +18,12 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ecodeclub/ekit/bean/option"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -294,3 +296,163 @@ func TestSqlRowsScanner_NextResultSet(t *testing.T) {
 		assert.False(t, scanner.NextResultSet())
 	})
 }
+
+func TestSqlRowsScanner_ScanBatch(t *testing.T) {
+	newRows := func(t *testing.T, cnt int) *sql.Rows {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = db.Close()
+		})
+		mockRows := sqlmock.NewRows([]string{"id", "name"})
+		for i := 1; i <= cnt; i++ {
+			mockRows.AddRow(i, fmt.Sprintf("name%d", i))
+		}
+		mock.ExpectQuery("SELECT").WillReturnRows(mockRows)
+		rows, err := db.Query("SELECT id, name FROM users")
+		require.NoError(t, err)
+		return rows
+	}
+
+	testCases := []struct {
+		name string
+		opts []option.Option[sqlRowsScanner]
+	}{
+		{
+			name: "不复用",
+		},
+		{
+			name: "复用",
+			opts: []option.Option[sqlRowsScanner]{WithReuseRowBuffer()},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := NewSQLRowsBatchScanner(newRows(t, 5), tc.opts...)
+			require.NoError(t, err)
+			_, err = s.ScanBatch(0)
+			assert.ErrorIs(t, err, errInvalidArgument)
+
+			batch, err := s.ScanBatch(2)
+			require.NoError(t, err)
+			assert.Equal(t, [][]any{{int64(1), "name1"}, {int64(2), "name2"}}, batch)
+			batch, err = s.ScanBatch(2)
+			require.NoError(t, err)
+			assert.Equal(t, [][]any{{int64(3), "name3"}, {int64(4), "name4"}}, batch)
+			// 剩余数据不足
+			batch, err = s.ScanBatch(2)
+			require.NoError(t, err)
+			assert.Equal(t, [][]any{{int64(5), "name5"}}, batch)
+			_, err = s.ScanBatch(2)
+			assert.ErrorIs(t, err, ErrNoMoreRows)
+		})
+	}
+
+	t.Run("迭代期间sql.Rows发生错误,ScanBatch应该报错", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+		expectedErr := errors.New("iteration error")
+		mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
+			AddRow(1, "John").
+			AddRow(2, "Jane").RowError(1, expectedErr))
+		rows, err := db.Query("SELECT id, name FROM users")
+		require.NoError(t, err)
+		defer rows.Close()
+
+		s, err := NewSQLRowsBatchScanner(rows)
+		require.NoError(t, err)
+		_, err = s.ScanBatch(2)
+		assert.Equal(t, expectedErr, err)
+	})
+}
+
+func TestSqlRowsScanner_ReuseRowBuffer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRowsWithColumnDefinition(
+		sqlmock.NewColumn("data").OfType("BLOB", sql.RawBytes{})).
+		AddRow([]byte("abc")).AddRow([]byte("def")).AddRow(nil).AddRow([]byte{}).AddRow([]byte("ghi")))
+	rows, err := db.Query("SELECT data FROM users")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	s, err := NewSQLRowsBatchScanner(rows, WithReuseRowBuffer())
+	require.NoError(t, err)
+	first, err := s.Scan()
+	require.NoError(t, err)
+	assert.Equal(t, []any{sql.RawBytes("abc")}, first)
+	second, err := s.Scan()
+	require.NoError(t, err)
+	// 复用同一个切片以及 sql.RawBytes 的内存
+	assert.Equal(t, []any{sql.RawBytes("def")}, first)
+	assert.Equal(t, &first[0], &second[0])
+	third, err := s.Scan()
+	require.NoError(t, err)
+	assert.Equal(t, []any{sql.RawBytes(nil)}, third)
+	// NULL 之后的空值依然是空值而不是 NULL
+	fourth, err := s.Scan()
+	require.NoError(t, err)
+	require.NotNil(t, fourth[0])
+	assert.NotNil(t, fourth[0].(sql.RawBytes))
+	assert.Len(t, fourth[0], 0)
+	fifth, err := s.Scan()
+	require.NoError(t, err)
+	assert.Equal(t, []any{sql.RawBytes("ghi")}, fifth)
+}
+
+func TestSqlRowsScanner_ScanStream(t *testing.T) {
+	newScanner := func(t *testing.T, mockRows *sqlmock.Rows) BatchScanner {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = db.Close()
+		})
+		mock.ExpectQuery("SELECT").WillReturnRows(mockRows)
+		rows, err := db.Query("SELECT id FROM users")
+		require.NoError(t, err)
+		s, err := NewSQLRowsBatchScanner(rows, WithReuseRowBuffer())
+		require.NoError(t, err)
+		return s
+	}
+
+	t.Run("正常结束", func(t *testing.T) {
+		s := newScanner(t, sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2).AddRow(3))
+		rowCh, errCh := s.ScanStream(context.Background(), 1)
+		var all [][]any
+		for row := range rowCh {
+			all = append(all, row)
+		}
+		assert.NoError(t, <-errCh)
+		// ScanStream 不受 WithReuseRowBuffer 影响
+		assert.Equal(t, [][]any{{int64(1)}, {int64(2)}, {int64(3)}}, all)
+	})
+
+	t.Run("迭代期间sql.Rows发生错误", func(t *testing.T) {
+		expectedErr := errors.New("iteration error")
+		s := newScanner(t, sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2).RowError(1, expectedErr))
+		rowCh, errCh := s.ScanStream(context.Background(), 0)
+		var all [][]any
+		for row := range rowCh {
+			all = append(all, row)
+		}
+		assert.Equal(t, expectedErr, <-errCh)
+		assert.Equal(t, [][]any{{int64(1)}}, all)
+	})
+
+	t.Run("取消ctx", func(t *testing.T) {
+		s := newScanner(t, sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2).AddRow(3))
+		ctx, cancel := context.WithCancel(context.Background())
+		rowCh, errCh := s.ScanStream(ctx, 0)
+		assert.Equal(t, []any{int64(1)}, <-rowCh)
+		cancel()
+		assert.Equal(t, context.Canceled, <-errCh)
+		// 剩余的数据不会再发送
+		for range rowCh {
+		}
+		// 取消之后依然可以继续使用 Scanner
+		_, err := s.Scan()
+		assert.NoError(t, err)
+	})
+}
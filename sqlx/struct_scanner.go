@@ -0,0 +1,280 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlx
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/ecodeclub/ekit/bean/option"
+)
+
+// ErrUnmappedColumn 严格模式下，结果集中的列找不到对应的字段
+var ErrUnmappedColumn = errors.New("ekit: 列没有对应的字段")
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	// 结构体类型到 *structPlan 的映射
+	structPlans sync.Map
+)
+
+type structScanOptions[T any] struct {
+	strict  bool
+	newFunc func() *T
+}
+
+// WithStrictColumns 开启严格模式，结果集中存在找不到对应字段的列时返回 ErrUnmappedColumn
+// 默认情况下这些列会被忽略
+func WithStrictColumns[T any]() option.Option[structScanOptions[T]] {
+	return func(opts *structScanOptions[T]) {
+		opts.strict = true
+	}
+}
+
+// WithNewFunc 指定创建 T 的方法，默认使用 new(T)
+// 一些字段在 Scan 之前需要初始化，例如 EncryptColumn 需要设置 Key 或者 Encryptor，
+// 这时候可以通过 fn 返回一个初始化好的 T，fn 每次都需要返回新的实例
+func WithNewFunc[T any](fn func() *T) option.Option[structScanOptions[T]] {
+	return func(opts *structScanOptions[T]) {
+		opts.newFunc = fn
+	}
+}
+
+// ScanStruct 读取下一行，并且按照列名映射为 T，T 必须是结构体
+// 列名和字段的映射规则：
+//   - 优先使用 db 标签，例如 `db:"user_name"`，`db:"-"` 代表忽略该字段
+//   - 没有 db 标签的字段使用字段名的蛇形命名，例如 UserID 对应 user_id
+//   - 列名不区分大小写
+//   - 组合的结构体（包括指针）会被展开，外层的字段优先；但是实现了 sql.Scanner 的组合结构体会被当作一个字段
+//   - 指针字段在列为 NULL 时为 nil，实现了 sql.Scanner 的字段（例如 JsonColumn、EncryptColumn）会使用自身的 Scan 方法
+//
+// 每个类型的映射规则只会解析一次并缓存起来
+// 没有更多数据时返回 ErrNoMoreRows，ScanStruct 不会关闭 rows，用户需要对此负责
+func ScanStruct[T any](rows Rows, opts ...option.Option[structScanOptions[T]]) (*T, error) {
+	m, err := newStructRowMapper[T](rows, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w", ErrNoMoreRows)
+	}
+	return m.scan(rows)
+}
+
+// ScanAllStructs 读取当前结果集的全部数据，映射规则参考 ScanStruct
+// 没有数据的时候返回空切片
+func ScanAllStructs[T any](rows Rows, opts ...option.Option[structScanOptions[T]]) ([]*T, error) {
+	m, err := newStructRowMapper[T](rows, opts...)
+	if err != nil {
+		return nil, err
+	}
+	all := make([]*T, 0, 32)
+	for rows.Next() {
+		t, err := m.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// structRowMapper 记录结果集中每一列对应的字段
+type structRowMapper[T any] struct {
+	// 每一列对应的字段下标路径，nil 代表忽略该列
+	indexes [][]int
+	newFunc func() *T
+}
+
+func newStructRowMapper[T any](rows Rows, opts ...option.Option[structScanOptions[T]]) (*structRowMapper[T], error) {
+	if rows == nil {
+		return nil, fmt.Errorf("%w rows不能为nil", errInvalidArgument)
+	}
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w T 必须是结构体，实际为 %v", errInvalidArgument, typ)
+	}
+	scanOpts := structScanOptions[T]{
+		newFunc: func() *T {
+			return new(T)
+		},
+	}
+	option.Apply(&scanOpts, opts...)
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	plan := structPlanOf(typ)
+	indexes := make([][]int, len(columns))
+	var unmapped []string
+	for i, col := range columns {
+		index, ok := plan.fields[strings.ToLower(col)]
+		if !ok {
+			unmapped = append(unmapped, col)
+			continue
+		}
+		indexes[i] = index
+	}
+	if scanOpts.strict && len(unmapped) > 0 {
+		return nil, fmt.Errorf("%w，类型 %v，列 %s", ErrUnmappedColumn, typ, strings.Join(unmapped, ", "))
+	}
+	return &structRowMapper[T]{indexes: indexes, newFunc: scanOpts.newFunc}, nil
+}
+
+// scan 将当前行映射为 T，调用者需要先调用 rows.Next
+func (m *structRowMapper[T]) scan(rows Rows) (*T, error) {
+	t := m.newFunc()
+	if err := rows.Scan(m.dests(t)...); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (m *structRowMapper[T]) dests(t *T) []any {
+	val := reflect.ValueOf(t).Elem()
+	dests := make([]any, len(m.indexes))
+	var discard any
+	for i, index := range m.indexes {
+		if index == nil {
+			dests[i] = &discard
+			continue
+		}
+		dests[i] = fieldAddr(val, index)
+	}
+	return dests
+}
+
+// fieldAddr 返回字段的地址，路径上为 nil 的组合指针会被初始化
+func fieldAddr(val reflect.Value, index []int) any {
+	for i, x := range index {
+		if i > 0 && val.Kind() == reflect.Pointer {
+			if val.IsNil() {
+				val.Set(reflect.New(val.Type().Elem()))
+			}
+			val = val.Elem()
+		}
+		val = val.Field(x)
+	}
+	return val.Addr().Interface()
+}
+
+// structPlan 是一个结构体类型的映射规则
+type structPlan struct {
+	// 小写的列名到字段下标路径的映射
+	fields map[string][]int
+}
+
+func structPlanOf(typ reflect.Type) *structPlan {
+	if plan, ok := structPlans.Load(typ); ok {
+		return plan.(*structPlan)
+	}
+	plan, _ := structPlans.LoadOrStore(typ, newStructPlan(typ))
+	return plan.(*structPlan)
+}
+
+// newStructPlan 按照层次遍历结构体，从而保证外层的字段优先
+func newStructPlan(typ reflect.Type) *structPlan {
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+	fields := make(map[string][]int)
+	visited := map[reflect.Type]struct{}{typ: {}}
+	current := []embedded{{typ: typ}}
+	for len(current) > 0 {
+		var next []embedded
+		// 同一层的字段先出现的优先
+		level := make(map[string][]int)
+		for _, e := range current {
+			for i := 0; i < e.typ.NumField(); i++ {
+				f := e.typ.Field(i)
+				tag := f.Tag.Get("db")
+				if tag == "-" {
+					continue
+				}
+				name, _, _ := strings.Cut(tag, ",")
+				index := make([]int, len(e.index)+1)
+				copy(index, e.index)
+				index[len(e.index)] = i
+				if f.Anonymous && name == "" {
+					ft := f.Type
+					isPtr := ft.Kind() == reflect.Pointer
+					if isPtr {
+						ft = ft.Elem()
+					}
+					if ft.Kind() == reflect.Struct && !isScanner(f.Type) {
+						// 非导出的组合指针无法初始化
+						if _, ok := visited[ft]; !ok && (f.IsExported() || !isPtr) {
+							visited[ft] = struct{}{}
+							next = append(next, embedded{typ: ft, index: index})
+						}
+						continue
+					}
+				}
+				if !f.IsExported() {
+					continue
+				}
+				if name == "" {
+					name = underscoreName(f.Name)
+				}
+				name = strings.ToLower(name)
+				if _, ok := fields[name]; ok {
+					continue
+				}
+				if _, ok := level[name]; !ok {
+					level[name] = index
+				}
+			}
+		}
+		for name, index := range level {
+			fields[name] = index
+		}
+		current = next
+	}
+	return &structPlan{fields: fields}
+}
+
+func isScanner(typ reflect.Type) bool {
+	return typ.Implements(scannerType) || reflect.PointerTo(typ).Implements(scannerType)
+}
+
+// underscoreName 将驼峰命名转化为蛇形命名，例如 UserID 转化为 user_id，HTTPServer 转化为 http_server
+func underscoreName(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	b.Grow(len(name) + 4)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (!unicode.IsUpper(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,233 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type scanBase struct {
+	ID   int64
+	Name string `db:"base_name"`
+}
+
+type ScanAudit struct {
+	CreatedBy string
+	// 被外层的 Remark 覆盖
+	Remark string
+}
+
+type scanAddress struct {
+	City string
+}
+
+type scanUser struct {
+	scanBase
+	*ScanAudit
+	Remark   string
+	UserName string `db:"name,omitempty"`
+	Nickname *string
+	Address  JsonColumn[scanAddress]
+	Phone    EncryptColumn[string]
+	Ignored  string `db:"-"`
+	unused   string
+}
+
+func newScanUserDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", "file:"+t.Name()+"?mode=memory&cache=shared")
+	require.NoError(t, err)
+	_, err = db.ExecContext(context.Background(), `CREATE TABLE users(
+    id INTEGER PRIMARY KEY,
+    base_name TEXT,
+    created_by TEXT,
+    remark TEXT,
+    name TEXT,
+    nickname TEXT,
+    address TEXT,
+    phone BLOB,
+    ignored TEXT,
+    unused TEXT
+)`)
+	require.NoError(t, err)
+	for _, nickname := range []*string{nil, func() *string { s := "Tom"; return &s }()} {
+		_, err = db.ExecContext(context.Background(),
+			"INSERT INTO users(base_name, created_by, remark, name, nickname, address, phone, ignored, unused) VALUES(?,?,?,?,?,?,?,?,?)",
+			"base", "admin", "remark", "user", nickname,
+			JsonColumn[scanAddress]{Val: scanAddress{City: "Shenzhen"}, Valid: true},
+			EncryptColumn[string]{Val: "13800000000", Valid: true, Key: testKeyV1}, "ignored", "unused")
+		require.NoError(t, err)
+	}
+	return db
+}
+
+func newScanUser() *scanUser {
+	return &scanUser{Phone: EncryptColumn[string]{Key: testKeyV1}}
+}
+
+func TestScanStruct(t *testing.T) {
+	db := newScanUserDB(t)
+	defer db.Close()
+
+	rows, err := db.QueryContext(context.Background(), "SELECT * FROM users ORDER BY id")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	u, err := ScanStruct[scanUser](rows, WithNewFunc(newScanUser))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), u.ID)
+	assert.Equal(t, "base", u.Name)
+	require.NotNil(t, u.ScanAudit)
+	assert.Equal(t, "admin", u.CreatedBy)
+	assert.Equal(t, "remark", u.Remark)
+	assert.Equal(t, "", u.ScanAudit.Remark)
+	assert.Equal(t, "user", u.UserName)
+	assert.Nil(t, u.Nickname)
+	assert.Equal(t, "Shenzhen", u.Address.Val.City)
+	assert.Equal(t, "13800000000", u.Phone.Val)
+	assert.Equal(t, "", u.Ignored)
+	assert.Equal(t, "", u.unused)
+
+	u, err = ScanStruct[scanUser](rows, WithNewFunc(newScanUser))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), u.ID)
+	require.NotNil(t, u.Nickname)
+	assert.Equal(t, "Tom", *u.Nickname)
+
+	_, err = ScanStruct[scanUser](rows, WithNewFunc(newScanUser))
+	assert.ErrorIs(t, err, ErrNoMoreRows)
+}
+
+func TestScanStruct_Strict(t *testing.T) {
+	db := newScanUserDB(t)
+	defer db.Close()
+
+	rows, err := db.QueryContext(context.Background(), "SELECT id, name, unused, ignored FROM users")
+	require.NoError(t, err)
+	defer rows.Close()
+	_, err = ScanStruct[scanUser](rows, WithStrictColumns[scanUser]())
+	assert.ErrorIs(t, err, ErrUnmappedColumn)
+	assert.ErrorContains(t, err, "unused, ignored")
+
+	rows, err = db.QueryContext(context.Background(), "SELECT ID, NAME FROM users")
+	require.NoError(t, err)
+	defer rows.Close()
+	u, err := ScanStruct[scanUser](rows, WithStrictColumns[scanUser]())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), u.ID)
+	assert.Equal(t, "user", u.UserName)
+}
+
+func TestScanStruct_InvalidArgument(t *testing.T) {
+	db := newScanUserDB(t)
+	defer db.Close()
+	rows, err := db.QueryContext(context.Background(), "SELECT id FROM users")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	_, err = ScanStruct[int](rows)
+	assert.ErrorIs(t, err, errInvalidArgument)
+	_, err = ScanStruct[scanUser](nil)
+	assert.ErrorIs(t, err, errInvalidArgument)
+}
+
+func TestScanAllStructs(t *testing.T) {
+	db := newScanUserDB(t)
+	defer db.Close()
+
+	rows, err := db.QueryContext(context.Background(), "SELECT id, nickname FROM users ORDER BY id")
+	require.NoError(t, err)
+	defer rows.Close()
+	users, err := ScanAllStructs[scanUser](rows)
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	assert.Equal(t, int64(1), users[0].ID)
+	assert.Nil(t, users[0].Nickname)
+	assert.Equal(t, int64(2), users[1].ID)
+	assert.Equal(t, "Tom", *users[1].Nickname)
+
+	rows, err = db.QueryContext(context.Background(), "SELECT id FROM users WHERE id > 10")
+	require.NoError(t, err)
+	defer rows.Close()
+	users, err = ScanAllStructs[scanUser](rows)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+}
+
+func TestScanAllStructs_Error(t *testing.T) {
+	mockErr := errors.New("mock error")
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}).
+		AddRow(1).AddRow(2).RowError(1, mockErr))
+	rows, err := db.Query("SELECT id FROM users")
+	require.NoError(t, err)
+	_, err = ScanAllStructs[scanUser](rows)
+	assert.ErrorIs(t, err, mockErr)
+
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("abc"))
+	rows, err = db.Query("SELECT id FROM users")
+	require.NoError(t, err)
+	_, err = ScanAllStructs[scanUser](rows)
+	assert.Error(t, err)
+}
+
+func TestUnderscoreName(t *testing.T) {
+	testCases := []struct {
+		name string
+		want string
+	}{
+		{name: "ID", want: "id"},
+		{name: "UserID", want: "user_id"},
+		{name: "HTTPServer", want: "http_server"},
+		{name: "CreatedAt", want: "created_at"},
+		{name: "name", want: "name"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, underscoreName(tc.name))
+		})
+	}
+}
+
+func TestStructPlanOf(t *testing.T) {
+	type withUnexportedPtr struct {
+		*scanAddress
+		ID int64
+	}
+	typ := reflect.TypeOf(withUnexportedPtr{})
+	plan := structPlanOf(typ)
+	// 非导出的组合指针无法初始化，所以被忽略
+	assert.Equal(t, map[string][]int{"id": {1}}, plan.fields)
+	// 映射规则会被缓存
+	assert.Same(t, plan, structPlanOf(typ))
+
+	plan = structPlanOf(reflect.TypeOf(scanUser{}))
+	assert.Equal(t, []int{0, 1}, plan.fields["base_name"])
+	assert.Equal(t, []int{1, 0}, plan.fields["created_by"])
+	assert.Equal(t, []int{2}, plan.fields["remark"])
+	assert.Equal(t, []int{3}, plan.fields["name"])
+	assert.NotContains(t, plan.fields, "ignored")
+	assert.NotContains(t, plan.fields, "unused")
+}